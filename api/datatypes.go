@@ -5,6 +5,8 @@ SPDX-License-Identifier: Apache-2.0
 
 package api
 
+import "time"
+
 // Marble data structure
 //
 type Marble struct {
@@ -34,9 +36,20 @@ type Transfer struct {
 // Response data structure for entity creation or transfer
 //
 type Response struct {
-	Id    string `json:"id"`              // entity id (owner or marble)
-	TxId  string `json:"txId"`            // fabric transaction id
-	Error string `json:"error,omitempty"` // error message if any from chaincode
+	Id        string   `json:"id"`                  // entity id (owner or marble)
+	TxId      string   `json:"txId"`                // fabric transaction id
+	Error     string   `json:"error,omitempty"`     // error message if any from chaincode
+	Retries   []Retry  `json:"retries,omitempty"`   // retries of the fabric transaction, if any
+	Endorsers []string `json:"endorsers,omitempty"` // addresses of the peers that endorsed the fabric transaction
+}
+
+// Retry is a retried attempt of a fabric transaction, with the status that triggered it
+//
+type Retry struct {
+	Group   string   `json:"group"` // fabric-sdk status group, eg. "Chaincode status"
+	Code    int32    `json:"code"`
+	Message string   `json:"message,omitempty"`
+	Peers   []string `json:"peers,omitempty"` // addresses of the peers the error is attributed to
 }
 
 type ClearMarblesResponse struct {
@@ -52,6 +65,71 @@ type InitBatchRequest struct {
 	DelaySeconds    int  `json:"delaySeconds"`    // delay_seconds indicates the time the worker will wait between transfers
 	ClearMarbles    bool `json:"clearMarbles"`    // clearMarbles indicates whether the client will delete all marbles from the ledger prior to the test
 	ExtraDataLength int  `json:"extraDataLength"` // extraDataLength specifies the size of extra data attached to the marble to increase block size
+
+	Scenario    string      `json:"scenario,omitempty"`    // scenario names the benchmark scenario this run belongs to, used for baseline comparison
+	SetBaseline bool        `json:"setBaseline,omitempty"` // setBaseline marks this run as the baseline of its scenario once it completes successfully
+	Tolerances  *Tolerances `json:"tolerances,omitempty"`  // tolerances optionally overrides the configured baseline comparison tolerances
+
+	Assertions *SLOAssertions `json:"assertions,omitempty"` // assertions are the SLOs the run must meet, a run failing any of them has status slo_violated
+
+	Profile *ProfileRequest `json:"profile,omitempty"` // profile requests profiles of the service to be captured during the transfers
+
+	Propagation *PropagationRequest `json:"propagation,omitempty"` // propagation requests commit propagation across peers to be measured
+
+	Identities string `json:"identities,omitempty"` // identities assigns the configured identities to workers, round_robin or random; all workers use the default user if empty
+
+	Organizations string `json:"organizations,omitempty"` // organizations assigns the configured organizations to workers, round_robin or random; all workers use the default organization if empty
+
+	Endorsers [][]string `json:"endorsers,omitempty"` // endorsers are sets of peer URLs assigned round-robin to workers, which get their transfers endorsed by these peers only
+	Orderers  []string   `json:"orderers,omitempty"`  // orderers are orderer names or URLs assigned round-robin to workers, which send their transactions to them only
+
+	PeerFilter string `json:"peerFilter,omitempty"` // peerFilter names a filter of fabric_sdk.peer_filters restricting the peers the transfers are sent to
+
+	ConsistencyCheck *ConsistencyCheckRequest `json:"consistencyCheck,omitempty"` // consistencyCheck requests the ledgers of all peers to be compared once the transfers complete
+}
+
+// ConsistencyCheckRequest lists what is compared across the ledgers of the peers of the channel, in addition to their heights
+// and current block hashes. Batch runs also compare the marbles and owners they transferred.
+//
+type ConsistencyCheckRequest struct {
+	Keys          []string `json:"keys,omitempty"`          // ledger keys, eg. marble or owner IDs
+	BatchIDs      []string `json:"batchIds,omitempty"`      // batch runs whose stored results are compared
+	Everything    bool     `json:"everything,omitempty"`    // compare all marbles and owners, as read by read_everything
+	SettleSeconds int      `json:"settleSeconds,omitempty"` // time given to the peers to commit the latest blocks before comparing
+}
+
+// PropagationRequest sets how the propagation of transfers to all peers of the channel is measured
+//
+type PropagationRequest struct {
+	SampleEvery    int `json:"sampleEvery,omitempty"`    // measure every n-th transfer of each worker, 10 by default
+	TimeoutSeconds int `json:"timeoutSeconds,omitempty"` // max time for a transfer to be visible at all peers, 30 by default
+	PollMillis     int `json:"pollMillis,omitempty"`     // interval between queries of each peer, 100 by default
+}
+
+// ProfileRequest lists the profiles of the service to capture during the transfers of a batch run
+//
+type ProfileRequest struct {
+	CPU                 bool `json:"cpu"`                           // capture a CPU profile
+	Heap                bool `json:"heap"`                          // capture heap snapshots when transfers start and end
+	HeapIntervalSeconds int  `json:"heapIntervalSeconds,omitempty"` // also capture heap snapshots periodically
+}
+
+// SLOAssertions are the service level objectives asserted on a batch run, only the assertions set are evaluated
+//
+type SLOAssertions struct {
+	MaxP99TransferSeconds     *float64 `json:"maxP99TransferSeconds,omitempty"`
+	MaxAverageTransferSeconds *float64 `json:"maxAverageTransferSeconds,omitempty"`
+	MinThroughput             *float64 `json:"minThroughput,omitempty"` // successful transfers per second
+	MaxErrorRate              *float64 `json:"maxErrorRate,omitempty"`  // failed transfers over attempted transfers
+}
+
+// AssertionResult is the outcome of evaluating a single SLO assertion
+//
+type AssertionResult struct {
+	Assertion string  `json:"assertion"`
+	Limit     float64 `json:"limit"`
+	Actual    float64 `json:"actual"`
+	Passed    bool    `json:"passed"`
 }
 
 type InitBatchResponse struct {
@@ -59,12 +137,287 @@ type InitBatchResponse struct {
 }
 
 type BatchResult struct {
-	Request                InitBatchRequest `json:"request"`
-	Status                 string           `json:"status"`
-	TotalSuccesses         int              `json:"totalSuccesses"`
-	TotalFailures          int              `json:"totalFailures"`
-	TotalSuccessSeconds    int              `json:"totalSuccessSeconds"`
-	AverageTransferSeconds float64          `json:"averageTransferSeconds"`
-	MinTransferSeconds     float64          `json:"minTransferSeconds"`
-	MaxTransferSeconds     float64          `json:"maxTransferSeconds"`
+	BatchID                string               `json:"batchId,omitempty"`
+	Request                InitBatchRequest     `json:"request"`
+	Status                 string               `json:"status"`
+	TotalSuccesses         int                  `json:"totalSuccesses"`
+	TotalFailures          int                  `json:"totalFailures"`
+	TotalSuccessSeconds    int                  `json:"totalSuccessSeconds"`
+	AverageTransferSeconds float64              `json:"averageTransferSeconds"`
+	MinTransferSeconds     float64              `json:"minTransferSeconds"`
+	MaxTransferSeconds     float64              `json:"maxTransferSeconds"`
+	P50TransferSeconds     float64              `json:"p50TransferSeconds"`
+	P90TransferSeconds     float64              `json:"p90TransferSeconds"`
+	P99TransferSeconds     float64              `json:"p99TransferSeconds"`
+	RunSeconds             float64              `json:"runSeconds"` // wall clock duration of the transfer phase
+	Throughput             float64              `json:"throughput"` // successful transfers per second
+	ErrorRate              float64              `json:"errorRate"`  // failed transfers over attempted transfers
+	TotalRetries           int                  `json:"totalRetries"`
+	RetryDistribution      map[int]int          `json:"retryDistribution,omitempty"` // number of transfers by number of retries they needed
+	RetriesByCause         map[string]int       `json:"retriesByCause,omitempty"`    // number of retries by status group/code that triggered them
+	EndorsementMismatches  int                  `json:"endorsementMismatches"`       // failed transfers whose endorsements differ
+	Identities             int                  `json:"identities,omitempty"`        // number of distinct identities the workers transacted as
+	Selection              *SelectionStrategies `json:"selection,omitempty"`         // strategies selecting the peers of invocations and queries
+	SDKPoolSize            int                  `json:"sdkPoolSize,omitempty"`       // number of SDK instances the transfers were spread over
+	Peers                  []PeerStats          `json:"peers,omitempty"`             // breakdown of transfers by endorsing peer
+	Organizations          []OrgStats           `json:"organizations,omitempty"`     // breakdown of transfers by organization of the workers
+	Blocks                 *BlockSummary        `json:"blocks,omitempty"`            // blocks committed on the channel during the transfers
+	Runtime                *RuntimeStats        `json:"runtime,omitempty"`           // runtime statistics of the service during the transfers
+	Propagation            *PropagationSummary  `json:"propagation,omitempty"`       // propagation of sampled transfers to all peers
+	Consistency            *ConsistencyReport   `json:"consistency,omitempty"`       // comparison of the ledgers of all peers once the transfers completed
+	Assertions             []AssertionResult    `json:"assertions,omitempty"`
+	Comparison             *BaselineComparison  `json:"comparison,omitempty"`
+}
+
+// PeerStats are the transfers endorsed by a peer and the errors attributed to it during a batch run
+//
+type PeerStats struct {
+	Peer                   string  `json:"peer"`     // peer address
+	Endorsed               int     `json:"endorsed"` // successful transfers endorsed by the peer
+	AverageTransferSeconds float64 `json:"averageTransferSeconds"`
+	P50TransferSeconds     float64 `json:"p50TransferSeconds"`
+	P90TransferSeconds     float64 `json:"p90TransferSeconds"`
+	P99TransferSeconds     float64 `json:"p99TransferSeconds"`
+	Failures               int     `json:"failures"` // failed transfers whose error is attributed to the peer
+	Retries                int     `json:"retries"`  // retried attempts whose error is attributed to the peer
+}
+
+// OrgStats are the transfers of the workers transacting as members of an organization
+//
+type OrgStats struct {
+	Org                    string  `json:"org"`
+	Workers                int     `json:"workers"`
+	Successes              int     `json:"successes"`
+	Failures               int     `json:"failures"`
+	AverageTransferSeconds float64 `json:"averageTransferSeconds"`
+	P50TransferSeconds     float64 `json:"p50TransferSeconds"`
+	P90TransferSeconds     float64 `json:"p90TransferSeconds"`
+	P99TransferSeconds     float64 `json:"p99TransferSeconds"`
+	Throughput             float64 `json:"throughput"` // successful transfers per second
+}
+
+// SelectionStrategies are the strategies selecting the endorsing peers of invocations and the peer of queries
+//
+type SelectionStrategies struct {
+	Invoke string `json:"invoke"`
+	Query  string `json:"query"`
+}
+
+// BlockSummary summarizes the blocks committed on the channel during a batch run, including transactions of other clients
+//
+type BlockSummary struct {
+	Blocks                      int            `json:"blocks"`
+	Transactions                int            `json:"transactions"`
+	AverageTransactionsPerBlock float64        `json:"averageTransactionsPerBlock"`
+	AverageBlockBytes           int            `json:"averageBlockBytes"`
+	MaxBlockBytes               int            `json:"maxBlockBytes"`
+	AverageBlockIntervalSeconds float64        `json:"averageBlockIntervalSeconds"` // time between blocks, as received by the service
+	MaxBlockIntervalSeconds     float64        `json:"maxBlockIntervalSeconds"`
+	CutBySize                   int            `json:"cutBySize"`    // blocks reaching the max message count or preferred max bytes of the orderer
+	CutByTimeout                int            `json:"cutByTimeout"` // blocks cut by the orderer batch timeout
+	InvalidTransactions         int            `json:"invalidTransactions"`
+	ValidationCodes             map[string]int `json:"validationCodes"` // number of transactions by validation code
+}
+
+// RuntimeStats are the runtime statistics of the service sampled during a batch run, and the profiles captured
+//
+type RuntimeStats struct {
+	Samples            int      `json:"samples"`
+	AverageGoroutines  float64  `json:"averageGoroutines"`
+	MaxGoroutines      int      `json:"maxGoroutines"`
+	MaxHeapAllocBytes  uint64   `json:"maxHeapAllocBytes"`
+	GCCount            uint32   `json:"gcCount"`
+	GCPauseTotalMillis float64  `json:"gcPauseTotalMillis"`
+	MaxGCPauseMillis   float64  `json:"maxGcPauseMillis"`
+	Profiles           []string `json:"profiles,omitempty"` // URLs of the profiles captured
+}
+
+// PropagationSummary is the distribution of the spread between the first and the last peer committing sampled transfers.
+// Peers are polled in rounds, hence spreads are only resolved to the poll interval plus the latency of the queries.
+//
+type PropagationSummary struct {
+	Peers                int     `json:"peers"`
+	Samples              int     `json:"samples"`
+	Incomplete           int     `json:"incomplete"`        // samples not committed at all peers within the timeout, not part of the distribution
+	ResolutionSeconds    float64 `json:"resolutionSeconds"` // poll interval, lower bound of the resolution of the spreads
+	AverageSpreadSeconds float64 `json:"averageSpreadSeconds"`
+	P50SpreadSeconds     float64 `json:"p50SpreadSeconds"`
+	P90SpreadSeconds     float64 `json:"p90SpreadSeconds"`
+	P99SpreadSeconds     float64 `json:"p99SpreadSeconds"`
+	MaxSpreadSeconds     float64 `json:"maxSpreadSeconds"`
+}
+
+// Tolerances are the allowed deviations from a baseline before a run is considered regressed
+//
+type Tolerances struct {
+	ThroughputPercent        float64 `json:"throughputPercent"`        // max allowed throughput drop, in percent
+	AverageLatencyPercent    float64 `json:"averageLatencyPercent"`    // max allowed average transfer time increase, in percent
+	PercentileLatencyPercent float64 `json:"percentileLatencyPercent"` // max allowed p50/p90/p99 transfer time increase, in percent
+	ErrorRate                float64 `json:"errorRate"`                // max allowed error rate increase, absolute (0.01 is one percentage point)
+}
+
+// Baseline is the reference batch run of a named scenario
+//
+type Baseline struct {
+	Scenario string      `json:"scenario"`
+	BatchID  string      `json:"batchId"`
+	Result   BatchResult `json:"result"`
+}
+
+// SetBaselineRequest marks an existing batch run as the baseline of a scenario
+//
+type SetBaselineRequest struct {
+	BatchID string `json:"batchId"`
+}
+
+// MetricDelta is the comparison of a single metric between a run and its baseline
+//
+type MetricDelta struct {
+	Metric       string  `json:"metric"`
+	Baseline     float64 `json:"baseline"`
+	Current      float64 `json:"current"`
+	Delta        float64 `json:"delta"`        // current - baseline
+	DeltaPercent float64 `json:"deltaPercent"` // delta relative to baseline, in percent
+	Tolerance    float64 `json:"tolerance"`    // allowed deviation, in percent except for errorRate
+	Regressed    bool    `json:"regressed"`
+}
+
+// BaselineComparison is the outcome of comparing a batch run with the baseline of its scenario
+//
+type BaselineComparison struct {
+	Scenario        string        `json:"scenario"`
+	BaselineBatchID string        `json:"baselineBatchId"`
+	Verdict         string        `json:"verdict"` // regressed or ok
+	Tolerances      Tolerances    `json:"tolerances"`
+	Deltas          []MetricDelta `json:"deltas"`
+}
+
+// ScheduleRequest creates a recurring batch run
+//
+type ScheduleRequest struct {
+	Cron          string           `json:"cron"`                    // standard 5-field cron expression, evaluated in the server's time zone
+	Request       InitBatchRequest `json:"request"`                 // batch request to run at each activation
+	OverlapPolicy string           `json:"overlapPolicy,omitempty"` // skip (default), allow or queue, applied when the previous run is still in progress
+}
+
+// Schedule is a recurring batch run
+//
+type Schedule struct {
+	ID            string           `json:"id"`
+	Cron          string           `json:"cron"`
+	Request       InitBatchRequest `json:"request"`
+	OverlapPolicy string           `json:"overlapPolicy"`
+	CreatedAt     time.Time        `json:"createdAt"`
+	NextRun       time.Time        `json:"nextRun"`
+	Running       int              `json:"running"` // number of runs of this schedule in progress
+	Queued        bool             `json:"queued"`  // a run is queued until the one in progress completes
+}
+
+// ScheduleExecution is an activation of a schedule
+//
+type ScheduleExecution struct {
+	BatchID     string     `json:"batchId,omitempty"`
+	ScheduledAt time.Time  `json:"scheduledAt"`
+	StartedAt   *time.Time `json:"startedAt,omitempty"`
+	FinishedAt  *time.Time `json:"finishedAt,omitempty"`
+	Status      string     `json:"status"` // running, skipped or the status of the batch run result
+}
+
+// LogLevel is the log level of a module
+//
+type LogLevel struct {
+	Module   string     `json:"module,omitempty"`   // module name, empty for the default level
+	Level    string     `json:"level"`              // one of critical, error, warning, notice, info, and debug
+	RevertAt *time.Time `json:"revertAt,omitempty"` // revertAt is when a time-boxed level reverts to the previous one
+}
+
+// LogLevels are the default log level and the levels of modules having their own
+//
+type LogLevels struct {
+	Default LogLevel   `json:"default"`
+	Modules []LogLevel `json:"modules"`
+}
+
+// SetLogLevelRequest changes the log level of a module, or the default level if no module is given
+//
+type SetLogLevelRequest struct {
+	Module          string `json:"module,omitempty"`
+	Level           string `json:"level"`
+	DurationSeconds int    `json:"durationSeconds,omitempty"` // durationSeconds time-boxes the change, the previous level is restored afterwards
+}
+
+// PeerHealth is the health of a peer as tracked by endorser selection
+//
+type PeerHealth struct {
+	Peer                string     `json:"peer"`
+	Circuit             string     `json:"circuit"`       // closed, open (out of selection) or half_open (probed)
+	Outstanding         int        `json:"outstanding"`   // proposals in progress
+	LatencyMillis       float64    `json:"latencyMillis"` // moving average of the proposal latencies
+	Proposals           int        `json:"proposals"`
+	Failures            int        `json:"failures"` // proposals failed because of the peer, chaincode errors excluded
+	ConsecutiveFailures int        `json:"consecutiveFailures"`
+	LastError           string     `json:"lastError,omitempty"`
+	LastErrorAt         *time.Time `json:"lastErrorAt,omitempty"`
+	Ejections           int        `json:"ejections"`              // times the peer was taken out of selection
+	EjectedUntil        *time.Time `json:"ejectedUntil,omitempty"` // end of the backoff of a peer out of selection
+}
+
+// ConsistencyReport compares the ledgers of the peers of the channel
+//
+type ConsistencyReport struct {
+	Consistent    bool              `json:"consistent"` // no key differs and peers at the same height have the same current block
+	Peers         []PeerLedger      `json:"peers"`
+	LaggingPeers  []string          `json:"laggingPeers,omitempty"` // peers below the highest ledger height
+	ForkedBlocks  []BlockDivergence `json:"forkedBlocks,omitempty"` // heights at which peers have different current blocks
+	KeysChecked   int               `json:"keysChecked"`
+	DifferingKeys []KeyDivergence   `json:"differingKeys,omitempty"`
+	Errors        []string          `json:"errors,omitempty"` // queries that failed, their peers are left out of the comparison
+}
+
+// PeerLedger is the ledger of the channel at a peer
+//
+type PeerLedger struct {
+	Peer             string `json:"peer"`
+	Height           uint64 `json:"height"`
+	CurrentBlockHash string `json:"currentBlockHash,omitempty"` // hex encoded
+	BlocksBehind     uint64 `json:"blocksBehind"`               // blocks below the highest ledger height
+	Error            string `json:"error,omitempty"`
+}
+
+// BlockDivergence lists the current block hashes of the peers at a ledger height
+//
+type BlockDivergence struct {
+	Height   uint64         `json:"height"`
+	Variants []ValueVariant `json:"variants"`
+}
+
+// KeyDivergence lists the values of a key at the peers
+//
+type KeyDivergence struct {
+	Key      string         `json:"key"`
+	Variants []ValueVariant `json:"variants"`
+}
+
+// ValueVariant is a value found at some of the peers
+//
+type ValueVariant struct {
+	Value   string   `json:"value,omitempty"`
+	Missing bool     `json:"missing,omitempty"` // the peers do not have the key
+	Peers   []string `json:"peers"`
+}
+
+// HealthResponse is the response of the health and readiness endpoints
+//
+type HealthResponse struct {
+	Status string        `json:"status"` // ok if all checks passed, failed otherwise
+	Checks []HealthCheck `json:"checks,omitempty"`
+}
+
+// HealthCheck is the result of a single readiness check
+//
+type HealthCheck struct {
+	Name            string  `json:"name"`
+	Status          string  `json:"status"` // one of ok, failed, and timeout
+	Error           string  `json:"error,omitempty"`
+	DurationSeconds float64 `json:"durationSeconds"`
 }
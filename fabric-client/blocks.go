@@ -0,0 +1,112 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package fabricclient
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric-sdk-go/pkg/client/event"
+	"github.com/hyperledger/fabric-sdk-go/pkg/client/ledger"
+	"github.com/hyperledger/fabric-sdk-go/third_party/github.com/hyperledger/fabric/protos/common"
+	pb "github.com/hyperledger/fabric-sdk-go/third_party/github.com/hyperledger/fabric/protos/peer"
+)
+
+// BlockInfo summarizes a block committed on a channel
+type BlockInfo struct {
+	Number          uint64
+	Size            int            // size of the protobuf encoded block, in bytes
+	Transactions    int            // number of transactions in the block
+	ValidationCodes map[string]int // number of transactions by validation code, eg. VALID or MVCC_READ_CONFLICT
+	ReceivedAt      time.Time
+	SourceURL       string // peer that delivered the block
+}
+
+// ListenBlocks delivers the blocks committed on a channel from now on, until stop is called.
+// The blocks channel is closed once stopped.
+//
+func (t *fabClient) ListenBlocks(channelID string) (blocks <-chan *BlockInfo, stop func(), err error) {
+	chProvider := t.NewChannelProvider(channelID)
+
+	// the event service is shared by all listeners, and when first connected it delivers the newest block already committed,
+	// hence blocks below the current height are ignored
+	ledgerClient, err := ledger.New(chProvider)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create ledger client for channel %s: %s", channelID, err)
+	}
+	info, err := ledgerClient.QueryInfo()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to query info of channel %s: %s", channelID, err)
+	}
+	height := info.BCI.Height
+
+	eventClient, err := event.New(chProvider, event.WithBlockEvents())
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create event client for channel %s: %s", channelID, err)
+	}
+	registration, events, err := eventClient.RegisterBlockEvent()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to register for block events of channel %s: %s", channelID, err)
+	}
+
+	out := make(chan *BlockInfo, 100)
+	done := make(chan struct{})
+	go func() {
+		defer close(out)
+		for {
+			select {
+			case e, ok := <-events:
+				if !ok {
+					return
+				}
+				if e.Block == nil || e.Block.Header == nil || e.Block.Header.Number < height {
+					continue
+				}
+				select {
+				case out <- newBlockInfo(e.Block, e.SourceURL, time.Now()):
+				case <-done:
+					return
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	stop = func() {
+		once.Do(func() {
+			eventClient.Unregister(registration)
+			close(done)
+		})
+	}
+	return out, stop, nil
+}
+
+func newBlockInfo(block *common.Block, sourceURL string, receivedAt time.Time) *BlockInfo {
+	info := &BlockInfo{
+		Number:          block.Header.Number,
+		Size:            proto.Size(block),
+		ValidationCodes: make(map[string]int),
+		ReceivedAt:      receivedAt,
+		SourceURL:       sourceURL,
+	}
+	if block.Data != nil {
+		info.Transactions = len(block.Data.Data)
+	}
+
+	// the transactions filter holds the validation code of each transaction of the block
+	var flags []byte
+	if block.Metadata != nil && len(block.Metadata.Metadata) > int(common.BlockMetadataIndex_TRANSACTIONS_FILTER) {
+		flags = block.Metadata.Metadata[common.BlockMetadataIndex_TRANSACTIONS_FILTER]
+	}
+	for i := 0; i < info.Transactions && i < len(flags); i++ {
+		info.ValidationCodes[pb.TxValidationCode(flags[i]).String()]++
+	}
+	return info
+}
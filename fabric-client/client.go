@@ -6,14 +6,15 @@ SPDX-License-Identifier: Apache-2.0
 package fabricclient
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"os"
+	"sort"
 	"strings"
-	"sync"
 
-	"github.com/hyperledger/fabric-sdk-go/pkg/common/providers/context"
+	sdkcontext "github.com/hyperledger/fabric-sdk-go/pkg/common/providers/context"
 	"github.com/securekey/marbles-perf/fabric-client/factory"
 	"github.com/securekey/marbles-perf/fabric-client/peerfilter"
 
@@ -26,11 +27,9 @@ import (
 	"github.com/hyperledger/fabric-sdk-go/pkg/client/channel"
 	"github.com/hyperledger/fabric-sdk-go/pkg/common/errors/retry"
 	"github.com/hyperledger/fabric-sdk-go/pkg/common/errors/status"
-	sdkcfg "github.com/hyperledger/fabric-sdk-go/pkg/core/config"
 	"github.com/hyperledger/fabric-sdk-go/pkg/fabsdk"
 	"github.com/hyperledger/fabric-sdk-go/third_party/github.com/hyperledger/fabric/protos/common"
 
-	"github.com/hyperledger/fabric-sdk-go/pkg/core/logging/modlog"
 	gologging "github.com/op/go-logging"
 )
 
@@ -47,6 +46,9 @@ type Client interface {
 	// QueryCC queries a chaincode
 	QueryCC(maxAttempts int, channelID string, chainCodeID string, args []string, transientData map[string][]byte) (data *CCResponse, err error)
 
+	// InvokeCCAtPeers invokes a chaincode on the specified channel, endorsed by the specified peers
+	InvokeCCAtPeers(channelID string, chainCodeID string, args []string, transientData map[string][]byte, peerURLs ...string) (data *CCResponse, err error)
+
 	// QueryCCAtPeer query a chaincode from specified peer
 	QueryCCAtPeer(maxAttempts int, channelID string, chainCodeID string, args []string, transientData map[string][]byte, peerURL string) (data *CCResponse, err error)
 
@@ -56,6 +58,12 @@ type Client interface {
 	// QueryCCAtOwnOrg query a chaincode from within one's own organziation
 	QueryCCAtOwnOrg(maxAttempts int, channelID string, chainCodeID string, args []string, transientData map[string][]byte) (data *CCResponse, err error)
 
+	// InvokeCCContext invokes a chaincode on the specified channel, the call being abandoned once ctx is done
+	InvokeCCContext(ctx context.Context, channelID string, chainCodeID string, args []string, transientData map[string][]byte, opts ...CallOption) (data *CCResponse, err error)
+
+	// QueryCCContext queries a chaincode on the specified channel, the call being abandoned once ctx is done
+	QueryCCContext(ctx context.Context, channelID string, chainCodeID string, args []string, transientData map[string][]byte, opts ...CallOption) (data *CCResponse, err error)
+
 	// ChannelClient returns a channel client for the given channel id
 	ChannelClient(channelID string) (*channel.Client, error)
 
@@ -72,7 +80,37 @@ type Client interface {
 	EventTimeoutSeconds() int
 
 	// NewChannelProvider returns a channel provider based on fabricSDK field of client
-	NewChannelProvider(channelID string) context.ChannelProvider
+	NewChannelProvider(channelID string) sdkcontext.ChannelProvider
+
+	// ListenBlocks delivers the blocks committed on a channel from now on, until stop is called
+	ListenBlocks(channelID string) (blocks <-chan *BlockInfo, stop func(), err error)
+
+	// PeerURLs returns the URLs of the peers of a channel
+	PeerURLs(channelID string) ([]string, error)
+
+	// LedgerInfo returns the height and current block hash of the ledger of a channel at each of its peers
+	LedgerInfo(channelID string) ([]PeerLedgerInfo, error)
+
+	// TransactionCommitted tells whether a transaction is committed in the ledger of a channel at each of the given peers
+	TransactionCommitted(channelID string, txID string, peerURLs []string) ([]bool, error)
+
+	// Identities returns the users calls can be made as with WithIdentity, the default user first
+	Identities() []string
+
+	// Orgs returns the organizations calls can be made as a member of with WithOrg, the default organization first
+	Orgs() []string
+
+	// SelectionStrategies returns the strategies selecting the endorsing peers of invocations and the peer of queries
+	SelectionStrategies() SelectionStrategies
+
+	// PeerHealth returns the health of the peers selected so far, including whether they are out of selection
+	PeerHealth() []factory.PeerHealth
+
+	// PoolSize returns the number of SDK instances calls are spread over
+	PoolSize() int
+
+	// PeerFilter returns a peer filter declared in configuration, by name, to be used with WithPeerFilter
+	PeerFilter(name string) (fabapi.TargetFilter, error)
 
 	// Close closes this client
 	Close()
@@ -82,6 +120,8 @@ type Client interface {
 type CCResponse struct {
 	Payload     []byte
 	FabricTxnID string
+	Endorsers   []string // addresses of the peers that endorsed (or answered the query)
+	Retries     []Retry  // retries needed before the call succeeded
 }
 
 type fabClient struct {
@@ -94,15 +134,20 @@ type fabClient struct {
 	orgChannelID        string
 	shareChannelClient  bool
 
-	chclientMutex      *sync.RWMutex
-	chClients          map[string]*channel.Client
-	chclientqueryMutex *sync.RWMutex
-	chClientsQuery     map[string]*channel.Client
-	queryRetryOpts     retry.Opts
-	invokeRetryOpts    retry.Opts
+	pool            *sdkPool // fabricSDK and fabricSDKQuery are those of the first shard
+	queryRetryOpts  retry.Opts
+	invokeRetryOpts retry.Opts
 
 	orgConfig *fabapi.OrganizationConfig
 	orgName   string
+
+	identities      []string // users calls can be made as, the default user first
+	knownIdentities map[string]bool
+	orgs            []string          // organizations calls can be made as a member of, the default organization first
+	orgUsers        map[string]string // user of each organization other than the default one
+
+	selection   SelectionStrategies
+	peerFilters map[string]fabapi.TargetFilter // filters declared in configuration, by lower case name
 }
 
 const (
@@ -140,11 +185,6 @@ func NewClient() (Client, error) {
 
 func (t *fabClient) init() error {
 
-	t.chClients = make(map[string]*channel.Client)
-	t.chClientsQuery = make(map[string]*channel.Client)
-	t.chclientMutex = &sync.RWMutex{}
-	t.chclientqueryMutex = &sync.RWMutex{}
-
 	if t.userID = viper.GetString(ConfigUserID); len(t.userID) == 0 {
 		return fmt.Errorf("configuration error, %s not set", ConfigUserID)
 	}
@@ -175,35 +215,43 @@ func (t *fabClient) init() error {
 	logger.Infof("effective invoke retry options: %s", string(invokeRetryOptsJSON))
 
 	// make fabric-sdk use our logger for logging for consistency
-	sdkLoggerProvider := &sdkLoggerProvider{logger}
-	modlog.InitLogger(sdkLoggerProvider)
+	initSDKLogging()
 
 	sdkConfData, err := SetupClientConfFile()
 	if err != nil {
 		return err
 	}
 
-	apiConfig := sdkcfg.FromRaw(sdkConfData, "yaml")
-	t.fabricSDK, err = fabsdk.New(
-		apiConfig,
-		fabsdk.WithServicePkg(factory.NewMPerfServiceProviderFactory(t.userID)))
+	invokeSelection, querySelection, err := selectionOptions()
+	if err != nil {
+		return err
+	}
+	t.selection = SelectionStrategies{Invoke: invokeSelection.Strategy, Query: querySelection.Strategy}
+	breaker, err := circuitBreakerOptions()
+	if err != nil {
+		return err
+	}
+	factory.ConfigureCircuitBreaker(breaker)
+	logger.Infof("selection strategies: %s for invocations, %s for queries", t.selection.Invoke, t.selection.Query)
+
+	serviceProviderFactory, err := factory.NewMPerfServiceProviderFactory(t.userID, invokeSelection)
 	if err != nil {
-		return fmt.Errorf("failed to create new SDK: %v", err)
+		return fmt.Errorf("configuration error, %s: %s", configSelectionInvokeStrategy, err)
 	}
 
 	// the peer filter used in query calls is not mandatory
 	// this is to allow peer-specific peer filter to query other peers in the rare event that the peer specified is shut down
 	isQueryPeerFilterMandatory := false
 
-	apiConfigQuery := sdkcfg.FromRaw(sdkConfData, "yaml")
-	t.fabricSDKQuery, err = fabsdk.New(
-		apiConfigQuery,
-		//fabsdk.WithCorePkg(factory.NewMPerfCoreProviderFactory()),
-		fabsdk.WithServicePkg(factory.NewMPerfQueryServiceProviderFactory(isQueryPeerFilterMandatory)))
-
+	queryServiceProviderFactory, err := factory.NewMPerfQueryServiceProviderFactory(isQueryPeerFilterMandatory, querySelection)
 	if err != nil {
-		return fmt.Errorf("failed to create new SDK for chaincode query: %v", err)
+		return fmt.Errorf("configuration error, %s: %s", configSelectionQueryStrategy, err)
+	}
+	if t.pool, err = newSDKPool(sdkConfData, serviceProviderFactory, queryServiceProviderFactory); err != nil {
+		return err
 	}
+	t.fabricSDK = t.pool.shards[0].fabricSDK
+	t.fabricSDKQuery = t.pool.shards[0].fabricSDKQuery
 
 	t.orgName, t.orgConfig, err = GetDefaultOrganizationConfig(t.fabricSDK)
 	if err != nil || t.orgConfig == nil {
@@ -214,7 +262,13 @@ func (t *fabClient) init() error {
 		return fmt.Errorf("failed to enroll member: %v", err)
 	}
 
-	return nil
+	if err := t.initPeerFilters(); err != nil {
+		return err
+	}
+	if err := t.initIdentities(); err != nil {
+		return err
+	}
+	return t.initOrganizations()
 }
 
 // AddExtraRetryableCodes Add extra retryable codes that are not part of SDK's default, but are needed for our application
@@ -259,10 +313,20 @@ func SetupClientConfFile() ([]byte, error) {
 
 // EnrollMemberIfNecessary ..
 func EnrollMemberIfNecessary(fabricSDK *fabsdk.FabricSDK, userID string) error {
+	return enrollMember(fabricSDK, "", userID, viper.GetString(configUserEnrollmentSecret))
+}
+
+// enrollMember enrolls a user of an organization, the default one if orgName is empty, with the given secret,
+// unless its credentials are in the credential store already
+func enrollMember(fabricSDK *fabsdk.FabricSDK, orgName string, userID string, enrollSecret string) error {
 
 	logger.Infof("In enrollMemberIfNecessary ...")
 
-	mspClient, err := msp.New(fabricSDK.Context())
+	var mspOpts []msp.ClientOption
+	if orgName != "" {
+		mspOpts = append(mspOpts, msp.WithOrg(orgName))
+	}
+	mspClient, err := msp.New(fabricSDK.Context(), mspOpts...)
 	if err != nil {
 		return fmt.Errorf("failed to create mspClient: %v", err)
 	}
@@ -273,7 +337,6 @@ func EnrollMemberIfNecessary(fabricSDK *fabsdk.FabricSDK, userID string) error {
 		return nil
 	}
 
-	enrollSecret := viper.GetString(configUserEnrollmentSecret)
 	if enrollSecret == "" {
 		return fmt.Errorf("failed to load user credentials (%v) and no user enrollment secret configured", err)
 	}
@@ -343,26 +406,65 @@ func extractFuncNameFromArgs(args []string) string {
 // InvokeCC invokes a chancode on the specified channel
 //
 func (t *fabClient) InvokeCC(channelID string, chainCodeID string, args []string, transientData map[string][]byte) (*CCResponse, error) {
+	return t.InvokeCCContext(context.Background(), channelID, chainCodeID, args, transientData)
+}
+
+// InvokeCCAtPeers invokes a chancode on the specified channel, endorsed by the given peers instead of those picked by selection
+//
+func (t *fabClient) InvokeCCAtPeers(channelID string, chainCodeID string, args []string, transientData map[string][]byte, peerURLs ...string) (*CCResponse, error) {
+	return t.InvokeCCContext(context.Background(), channelID, chainCodeID, args, transientData, WithEndorsers(peerURLs...))
+}
+
+// InvokeCCContext invokes a chancode on the specified channel within ctx
+//
+func (t *fabClient) InvokeCCContext(ctx context.Context, channelID string, chainCodeID string, args []string, transientData map[string][]byte, opts ...CallOption) (ccResp *CCResponse, err error) {
 
 	logger.Debugf("--> InvokeCC: %s %s %s", channelID, chainCodeID, extractFuncNameFromArgs(args))
 
+	ctx, span := startCCSpan(ctx, "fabClient.InvokeCC", channelID, chainCodeID, args)
+	defer func() { endCCSpan(span, ccResp, err) }()
+
+	metrics := startCCCallMetrics(operationInvoke, args)
+	defer func() {
+		ccResp, err = attachRetries(metrics.retries, ccResp, err)
+		metrics.done(err)
+	}()
+
 	request := t.buildTxnRequest(channelID, chainCodeID, args, transientData)
 
-	chClient, err := t.ChannelClient(channelID)
+	callOpts := newCallOptions(opts)
+	if err := t.resolvePeerFilter(callOpts); err != nil {
+		return nil, err
+	}
+	orgName, userID, err := t.identity(callOpts)
+	if err != nil {
+		return nil, err
+	}
+	shard := t.pool.shard(callOpts.shardKey)
+	defer startShardCall(shard, operationInvoke)()
+	chClient, err := t.identityChannelClient(shard, channelID, orgName, userID)
 	if err != nil {
 		return nil, err
 	}
 	defer t.CloseChannelClient(chClient)
 
-	resp, err := chClient.Execute(request, channel.WithRetry(t.invokeRetryOpts))
+	if callOpts.orderer != "" {
+		ctx = factory.WithOrderer(ctx, callOpts.orderer)
+	}
+	requestOpts := callOpts.requestOptions(ctx, t.invokeRetryOpts, metrics.beforeRetry)
+	if requestOpts, err = t.targetOptions(shard, false, channelID, orgName, userID, callOpts, requestOpts); err != nil {
+		return nil, err
+	}
+	resp, err := chClient.Execute(request, requestOpts...)
 	if err != nil {
-		return nil, fmt.Errorf("fabClient invokeCC failed for %v: %v", args, err)
+		return nil, newError(fmt.Sprintf("fabClient invokeCC failed for %v", args), err, string(resp.TransactionID))
 	}
 
 	return t.extractCCResponse(&resp)
 }
 
-// extractCCResponse extracts chaincode response from TransactionProposalResponse
+// extractCCResponse extracts chaincode response from TransactionProposalResponse,
+// a non-successful chaincode status being returned as an Error
 //
 func (t *fabClient) extractCCResponse(txnResp *channel.Response) (*CCResponse, error) {
 
@@ -374,14 +476,21 @@ func (t *fabClient) extractCCResponse(txnResp *channel.Response) (*CCResponse, e
 	txnProposalResponse := txnResp.Responses[0]
 	if txnProposalResponse != nil && txnProposalResponse.Status != 200 {
 		var errmsg string
+		ccStatus := txnProposalResponse.Status
 		if txnProposalResponse.ProposalResponse != nil && txnProposalResponse.ProposalResponse.Response != nil {
 			errmsg = txnProposalResponse.ProposalResponse.Response.Message
+			ccStatus = txnProposalResponse.ProposalResponse.Response.Status
 		}
-		return nil, fmt.Errorf(errmsg)
+		return nil, newChaincodeError(ccStatus, errmsg, string(txnResp.TransactionID), txnProposalResponse.Endorser)
 	}
 	ccResponse := CCResponse{
 		FabricTxnID: string(txnResp.TransactionID),
 	}
+	for _, response := range txnResp.Responses {
+		if response != nil {
+			ccResponse.Endorsers = append(ccResponse.Endorsers, response.Endorser)
+		}
+	}
 	//	txnProposalResponse.ProposalResponse.GetResponse().Payload
 	if txnProposalResponse != nil && txnProposalResponse.ProposalResponse != nil {
 		if resp := txnProposalResponse.ProposalResponse.GetResponse(); resp != nil {
@@ -397,65 +506,67 @@ func (t *fabClient) extractCCResponse(txnResp *channel.Response) (*CCResponse, e
 func (t *fabClient) QueryCC(maxAttempts int, channelID string, chainCodeID string, args []string, transientData map[string][]byte) (*CCResponse, error) {
 	logger.Debugf("--> QueryCC: %s %s %s", channelID, chainCodeID, extractFuncNameFromArgs(args))
 
-	retryOpts := t.queryRetryOpts
-	if maxAttempts > retryOpts.Attempts {
-		retryOpts.Attempts = maxAttempts
-	}
-
-	chClient, err := t.ChannelClientQuery(channelID)
-	if err != nil {
-		return nil, err
-	}
-	defer t.CloseChannelClient(chClient)
-
-	resp, err := chClient.Query(t.buildTxnRequest(channelID, chainCodeID, args, transientData), channel.WithRetry(retryOpts))
-	if err != nil {
-		return nil, err
-	}
-
-	return t.extractCCResponse(&resp)
+	return t.QueryCCContext(context.Background(), channelID, chainCodeID, args, transientData, t.queryAttempts(maxAttempts)...)
 }
 
 func (t *fabClient) QueryCCAtPeer(maxAttempts int, channelID string, chainCodeID string, args []string, transientData map[string][]byte, peerURL string) (*CCResponse, error) {
 	logger.Debugf("--> QueryCCAtPeer: %s %s %s", channelID, chainCodeID, extractFuncNameFromArgs(args))
 
-	retryOpts := t.queryRetryOpts
-	if maxAttempts > retryOpts.Attempts {
-		retryOpts.Attempts = maxAttempts
-	}
-
-	chClient, err := t.ChannelClientQuery(channelID)
-	if err != nil {
-		return nil, err
-	}
-	defer t.CloseChannelClient(chClient)
-
-	resp, err := chClient.Query(t.buildTxnRequest(channelID, chainCodeID, args, transientData), channel.WithTargetFilter(peerfilter.URLFilter{PeerURL: peerURL}), channel.WithRetry(retryOpts))
-	if err != nil {
-		return nil, err
-	}
-
-	return t.extractCCResponse(&resp)
+	opts := append(t.queryAttempts(maxAttempts), WithPeerFilter(peerfilter.URLFilter{PeerURL: peerURL}))
+	return t.QueryCCContext(context.Background(), channelID, chainCodeID, args, transientData, opts...)
 }
 
 func (t *fabClient) QueryCCAtMSP(maxAttempts int, channelID string, chainCodeID string, args []string, transientData map[string][]byte, MSPID string) (*CCResponse, error) {
 	logger.Debugf("--> QueryCCAtMSP: %s %s %s", channelID, chainCodeID, extractFuncNameFromArgs(args))
 
-	retryOpts := t.queryRetryOpts
-	if maxAttempts > retryOpts.Attempts {
-		retryOpts.Attempts = maxAttempts
+	opts := append(t.queryAttempts(maxAttempts), WithPeerFilter(peerfilter.MSPFilter{MSPID: MSPID}))
+	return t.QueryCCContext(context.Background(), channelID, chainCodeID, args, transientData, opts...)
+}
+
+// queryAttempts returns the options of a query with maxAttempts, which only raises the configured attempts
+func (t *fabClient) queryAttempts(maxAttempts int) []CallOption {
+	if maxAttempts > t.queryRetryOpts.Attempts {
+		return []CallOption{WithMaxAttempts(maxAttempts)}
 	}
+	return nil
+}
 
-	chClient, err := t.ChannelClientQuery(channelID)
+// QueryCCContext queries a chancode on the specified channel within ctx
+//
+func (t *fabClient) QueryCCContext(ctx context.Context, channelID string, chainCodeID string, args []string, transientData map[string][]byte, opts ...CallOption) (ccResp *CCResponse, err error) {
+	ctx, span := startCCSpan(ctx, "fabClient.QueryCC", channelID, chainCodeID, args)
+	defer func() { endCCSpan(span, ccResp, err) }()
+
+	metrics := startCCCallMetrics(operationQuery, args)
+	defer func() {
+		ccResp, err = attachRetries(metrics.retries, ccResp, err)
+		metrics.done(err)
+	}()
+
+	callOpts := newCallOptions(opts)
+	if err := t.resolvePeerFilter(callOpts); err != nil {
+		return nil, err
+	}
+	orgName, userID, err := t.identity(callOpts)
+	if err != nil {
+		return nil, err
+	}
+	shard := t.pool.shard(callOpts.shardKey)
+	defer startShardCall(shard, operationQuery)()
+	chClient, err := t.identityChannelClientQuery(shard, channelID, orgName, userID)
 	if err != nil {
 		return nil, err
 	}
 	defer t.CloseChannelClient(chClient)
 
-	resp, err := chClient.Query(t.buildTxnRequest(channelID, chainCodeID, args, transientData), channel.WithTargetFilter(peerfilter.MSPFilter{MSPID: MSPID}), channel.WithRetry(retryOpts))
-	if err != nil {
+	requestOpts := callOpts.requestOptions(ctx, t.queryRetryOpts, metrics.beforeRetry)
+	if requestOpts, err = t.targetOptions(shard, true, channelID, orgName, userID, callOpts, requestOpts); err != nil {
 		return nil, err
 	}
+	resp, err := chClient.Query(t.buildTxnRequest(channelID, chainCodeID, args, transientData), requestOpts...)
+	if err != nil {
+		return nil, newError(fmt.Sprintf("fabClient queryCC failed for %v", args), err, string(resp.TransactionID))
+	}
 
 	return t.extractCCResponse(&resp)
 }
@@ -467,28 +578,39 @@ func (t *fabClient) QueryCCAtOwnOrg(maxAttempts int, channelID string, chainCode
 
 // ChannelClient returns a channelClient for the specified channel ID
 func (t *fabClient) ChannelClient(channelID string) (*channel.Client, error) {
+	return t.identityChannelClient(t.pool.shards[0], channelID, t.orgName, t.userID)
+}
+
+// identityChannelClient returns a channelClient for the specified channel ID, signing as the given user of an organization
+func (t *fabClient) identityChannelClient(shard *sdkShard, channelID string, orgName string, userID string) (*channel.Client, error) {
 
 	if !t.shareChannelClient {
-		return t.newChannelClient(channelID)
+		return t.newChannelClient(shard, channelID, orgName, userID)
 	}
 
-	t.chclientMutex.RLock()
-	chClient, exists := t.chClients[channelID]
-	t.chclientMutex.RUnlock()
+	key := channelClientKey(channelID, orgName, userID)
+	shard.chclientMutex.RLock()
+	chClient, exists := shard.chClients[key]
+	shard.chclientMutex.RUnlock()
 
 	if !exists {
 		var err error
-		chClient, err = t.newChannelClient(channelID)
+		chClient, err = t.newChannelClient(shard, channelID, orgName, userID)
 		if err != nil {
 			return nil, err
 		}
-		t.chclientMutex.Lock()
-		t.chClients[channelID] = chClient
-		t.chclientMutex.Unlock()
+		shard.chclientMutex.Lock()
+		shard.chClients[key] = chClient
+		shard.chclientMutex.Unlock()
 	}
 	return chClient, nil
 }
 
+// channelClientKey is the key of the channel clients of a user in the channel client caches
+func channelClientKey(channelID string, orgName string, userID string) string {
+	return channelID + "/" + orgName + "/" + userID
+}
+
 // ConsortiumChannel return channel ID of consortium channel
 func (t *fabClient) ConsortiumChannelID() string {
 	return consortiumChannelID
@@ -499,9 +621,9 @@ func (t *fabClient) OrgChannelID() string {
 	return t.orgChannelID
 }
 
-func (t *fabClient) newChannelClient(channelID string) (*channel.Client, error) {
+func (t *fabClient) newChannelClient(shard *sdkShard, channelID string, orgName string, userID string) (*channel.Client, error) {
 
-	chProvider := t.fabricSDK.ChannelContext(channelID, fabsdk.WithUser(t.userID), fabsdk.WithOrg(t.orgName))
+	chProvider := shard.fabricSDK.ChannelContext(channelID, fabsdk.WithUser(userID), fabsdk.WithOrg(orgName))
 	chClient, err := channel.New(chProvider)
 	if err != nil {
 		return nil, fmt.Errorf("newChannelClient: failed to obtain ChannelClient for %s, channel: %v", channelID, err)
@@ -509,40 +631,97 @@ func (t *fabClient) newChannelClient(channelID string) (*channel.Client, error)
 	return chClient, nil
 }
 
-func (t *fabClient) NewChannelProvider(channelID string) context.ChannelProvider {
+func (t *fabClient) NewChannelProvider(channelID string) sdkcontext.ChannelProvider {
 
 	return t.fabricSDK.ChannelContext(channelID, fabsdk.WithUser(t.userID), fabsdk.WithOrg(t.orgName))
 }
 
+// PeerURLs returns the URLs of the peers of a channel, as discovered by the SDK
+//
+func (t *fabClient) PeerURLs(channelID string) ([]string, error) {
+	peers, err := discoverPeers(t.NewChannelProvider(channelID), channelID)
+	if err != nil {
+		return nil, err
+	}
+
+	var urls []string
+	for _, peer := range peers {
+		urls = append(urls, peer.URL())
+	}
+	sort.Strings(urls)
+	return urls, nil
+}
+
+// discoverPeers returns the peers of a channel, as discovered by the SDK
+func discoverPeers(chProvider sdkcontext.ChannelProvider, channelID string) ([]fabapi.Peer, error) {
+	chContext, err := chProvider()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create context for channel %s: %s", channelID, err)
+	}
+	discovery, err := chContext.ChannelService().Discovery()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get discovery service of channel %s: %s", channelID, err)
+	}
+	peers, err := discovery.GetPeers()
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover peers of channel %s: %s", channelID, err)
+	}
+	return peers, nil
+}
+
+// targetOptions adds the peers set with WithTargets and WithEndorsers as targets of a call
+func (t *fabClient) targetOptions(shard *sdkShard, query bool, channelID string, orgName string, userID string, callOpts *callOptions, requestOpts []channel.RequestOption) ([]channel.RequestOption, error) {
+	peerURLs := append(append([]string(nil), callOpts.targets...), callOpts.endorsers...)
+	if len(peerURLs) == 0 {
+		return requestOpts, nil
+	}
+
+	targets := make([]fabapi.Peer, 0, len(peerURLs))
+	for _, peerURL := range peerURLs {
+		peer, err := shard.peer(query, channelID, orgName, userID, peerURL)
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, peer)
+	}
+	return append(requestOpts, channel.WithTargets(targets...)), nil
+}
+
 // ChannelClient returns a channelClient for the specified channel ID
 func (t *fabClient) ChannelClientQuery(channelID string) (*channel.Client, error) {
+	return t.identityChannelClientQuery(t.pool.shards[0], channelID, t.orgName, t.userID)
+}
+
+// identityChannelClientQuery returns a channelClient for queries on the specified channel ID, signing as the given user of an organization
+func (t *fabClient) identityChannelClientQuery(shard *sdkShard, channelID string, orgName string, userID string) (*channel.Client, error) {
 
 	if !t.shareChannelClient {
-		return t.newChannelClientQuery(channelID)
+		return t.newChannelClientQuery(shard, channelID, orgName, userID)
 	}
 
-	t.chclientqueryMutex.RLock()
-	chClient, exists := t.chClientsQuery[channelID]
-	t.chclientqueryMutex.RUnlock()
+	key := channelClientKey(channelID, orgName, userID)
+	shard.chclientqueryMutex.RLock()
+	chClient, exists := shard.chClientsQuery[key]
+	shard.chclientqueryMutex.RUnlock()
 
 	if !exists {
 		var err error
-		chClient, err = t.newChannelClientQuery(channelID)
+		chClient, err = t.newChannelClientQuery(shard, channelID, orgName, userID)
 		if err != nil {
 			return nil, err
 		}
-		t.chclientqueryMutex.Lock()
-		t.chClientsQuery[channelID] = chClient
-		t.chclientqueryMutex.Unlock()
+		shard.chclientqueryMutex.Lock()
+		shard.chClientsQuery[key] = chClient
+		shard.chclientqueryMutex.Unlock()
 	}
 	return chClient, nil
 }
 
-func (t *fabClient) newChannelClientQuery(channelID string) (*channel.Client, error) {
+func (t *fabClient) newChannelClientQuery(shard *sdkShard, channelID string, orgName string, userID string) (*channel.Client, error) {
 
 	var err error
 	var chClient *channel.Client
-	chProvider := t.fabricSDKQuery.ChannelContext(channelID, fabsdk.WithUser(t.userID), fabsdk.WithOrg(t.orgName))
+	chProvider := shard.fabricSDKQuery.ChannelContext(channelID, fabsdk.WithUser(userID), fabsdk.WithOrg(orgName))
 	chClient, err = channel.New(chProvider)
 
 	if err != nil {
@@ -570,8 +749,7 @@ func (t *fabClient) Close() {
 	//	chclient.Close()
 	//}
 	//t.chclientMutex.Unlock()
-	t.fabricSDK.Close()
-	t.fabricSDKQuery.Close()
+	t.pool.close()
 }
 
 // EventTimeoutSeconds returns the configured or default timeout value in seconds for chaincode/transaction event
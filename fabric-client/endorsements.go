@@ -0,0 +1,20 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package fabricclient
+
+import (
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/errors/status"
+)
+
+// IsEndorsementMismatch tells whether the endorsements of a call differ. fabric-sdk validates the endorsements of every
+// invocation and query before the transaction is sent for commit: it rejects endorsements with a non-success status and
+// compares their proposal response payloads, chaincode results and read/write set included, across endorsers.
+// Differing endorsements point to non-deterministic chaincode or diverged peers.
+//
+func IsEndorsementMismatch(err error) bool {
+	e, ok := AsError(err)
+	return ok && e.Group == status.EndorserClientStatus && e.Code == status.EndorsementMismatch.ToInt32()
+}
@@ -0,0 +1,31 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package fabricclient
+
+import (
+	"regexp"
+)
+
+// fabric-sdk wraps the errors of a peer while processing a proposal as "Transaction processing for endorser [<address>]: ..."
+var endorserErrorPattern = regexp.MustCompile(`Transaction processing for endorser \[([^\]]+)\]`)
+
+// ErrorPeers returns the addresses of the endorsing peers an error of a chaincode call is attributed to
+//
+func ErrorPeers(err error) []string {
+	if err == nil {
+		return nil
+	}
+
+	var peers []string
+	seen := make(map[string]bool)
+	for _, match := range endorserErrorPattern.FindAllStringSubmatch(err.Error(), -1) {
+		if !seen[match[1]] {
+			seen[match[1]] = true
+			peers = append(peers, match[1])
+		}
+	}
+	return peers
+}
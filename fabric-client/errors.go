@@ -0,0 +1,131 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package fabricclient
+
+import (
+	"context"
+	"errors"
+
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/errors/status"
+	pb "github.com/hyperledger/fabric-sdk-go/third_party/github.com/hyperledger/fabric/protos/peer"
+	grpccodes "google.golang.org/grpc/codes"
+)
+
+// Error is the error of a chaincode invocation or query, carrying the Fabric status it failed with
+//
+type Error struct {
+	Group           status.Group // status group, eg. status.EndorserClientStatus
+	Code            int32        // status code within the group
+	ChaincodeStatus int32        // status returned by the chaincode, 0 unless the chaincode rejected the call
+	Message         string       // status message
+	TxID            string       // fabric transaction ID, empty if the call failed before a transaction was proposed
+	Peer            string       // address of the peer the error is attributed to, empty if unknown
+	Retries         []Retry      // retries made before the call failed
+
+	msg   string
+	cause error
+}
+
+// Error implements error
+func (e *Error) Error() string {
+	return e.msg
+}
+
+// Unwrap returns the error returned by fabric-sdk, if any
+func (e *Error) Unwrap() error {
+	return e.cause
+}
+
+// newError classifies an error returned by fabric-sdk for a call, msg describing the call
+func newError(msg string, err error, txID string) *Error {
+	e := &Error{msg: msg + ": " + err.Error(), cause: err, TxID: txID, Group: status.UnknownStatus, Message: err.Error()}
+	if s, ok := errorStatus(err); ok {
+		e.Group = s.Group
+		e.Code = s.Code
+		e.Message = s.Message
+	}
+	if e.Group == status.ChaincodeStatus {
+		e.ChaincodeStatus = e.Code
+	}
+	if peers := ErrorPeers(err); len(peers) > 0 {
+		e.Peer = peers[0]
+	}
+	return e
+}
+
+// newChaincodeError is the error of a chaincode answering with a non-successful status
+func newChaincodeError(chaincodeStatus int32, message, txID, peer string) *Error {
+	return &Error{
+		Group:           status.ChaincodeStatus,
+		Code:            chaincodeStatus,
+		ChaincodeStatus: chaincodeStatus,
+		Message:         message,
+		TxID:            txID,
+		Peer:            peer,
+		msg:             message,
+	}
+}
+
+// errorStatus returns the status of an error of fabric-sdk, the first one found if several peers failed
+func errorStatus(err error) (*status.Status, bool) {
+	s, ok := status.FromError(err)
+	if ok && s.Group == status.ClientStatus && s.Code == status.MultipleErrors.ToInt32() {
+		for _, detail := range s.Details {
+			if peerErr, isErr := detail.(error); isErr {
+				if peerStatus, found := status.FromError(peerErr); found {
+					return peerStatus, true
+				}
+			}
+		}
+	}
+	return s, ok
+}
+
+// AsError returns the Error of a failed chaincode call, err possibly wrapping it
+//
+func AsError(err error) (*Error, bool) {
+	var e *Error
+	if errors.As(err, &e) {
+		return e, true
+	}
+	return nil, false
+}
+
+// IsMVCCConflict tells whether a transaction was invalidated by the committing peer because of a read conflict
+//
+func IsMVCCConflict(err error) bool {
+	e, ok := AsError(err)
+	if !ok || e.Group != status.EventServerStatus {
+		return false
+	}
+	return e.Code == int32(pb.TxValidationCode_MVCC_READ_CONFLICT) || e.Code == int32(pb.TxValidationCode_PHANTOM_READ_CONFLICT)
+}
+
+// IsChaincodeError tells whether a call was rejected by the chaincode, see Error.ChaincodeStatus for its status
+//
+func IsChaincodeError(err error) bool {
+	e, ok := AsError(err)
+	return ok && e.Group == status.ChaincodeStatus
+}
+
+// IsTimeout tells whether a call timed out, either waiting for peers and orderers or for the commit of its transaction
+//
+func IsTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	e, ok := AsError(err)
+	if !ok {
+		return false
+	}
+	switch e.Group {
+	case status.ClientStatus, status.EndorserClientStatus, status.OrdererClientStatus:
+		return e.Code == status.Timeout.ToInt32()
+	case status.GRPCTransportStatus:
+		return e.Code == int32(grpccodes.DeadlineExceeded)
+	}
+	return false
+}
@@ -0,0 +1,171 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package factory
+
+import (
+	"context"
+	"time"
+
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/errors/status"
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/providers/fab"
+)
+
+// States of the circuit breaker of a peer
+const (
+	CircuitClosed   = "closed"    // the peer is selected
+	CircuitOpen     = "open"      // the peer is out of selection until its backoff elapses
+	CircuitHalfOpen = "half_open" // the backoff elapsed, a single probe proposal decides whether the peer is back
+)
+
+// CircuitBreakerOptions configure how peers that keep failing are taken out of selection
+type CircuitBreakerOptions struct {
+	Enabled          bool
+	FailureThreshold int           // consecutive failures taking a peer out of selection
+	Backoff          time.Duration // time out of selection after the first ejection, doubled after every failed probe
+	MaxBackoff       time.Duration
+}
+
+// DefaultCircuitBreakerOptions are the circuit breaker options unless configured otherwise
+var DefaultCircuitBreakerOptions = CircuitBreakerOptions{
+	Enabled:          true,
+	FailureThreshold: 5,
+	Backoff:          10 * time.Second,
+	MaxBackoff:       2 * time.Minute,
+}
+
+// ConfigureCircuitBreaker sets the circuit breaker options of all service providers
+//
+func ConfigureCircuitBreaker(opts CircuitBreakerOptions) {
+	tracker.lock.Lock()
+	defer tracker.lock.Unlock()
+	tracker.breaker = opts
+}
+
+// peerFailure returns the error of a proposal if it is the peer's fault, nil otherwise.
+// A chaincode error is an answer of the peer, and a call cancelled by its caller tells nothing about the peer.
+func peerFailure(ctx context.Context, err error) error {
+	if err == nil || ctx.Err() == context.Canceled {
+		return nil
+	}
+	if s, ok := status.FromError(err); ok && s.Group == status.ChaincodeStatus {
+		return nil
+	}
+	return err
+}
+
+// recordFailure counts a failure of a peer, opening its circuit if it keeps failing; the lock must be held
+func (t *peerTracker) recordFailure(url string, state *peerState, err error) {
+	state.failures++
+	state.consecutiveFailures++
+	state.lastError = err.Error()
+	state.lastErrorAt = t.now()
+	if !t.breaker.Enabled {
+		return
+	}
+
+	switch {
+	case state.circuit == CircuitHalfOpen:
+		backoff := 2 * state.backoff
+		if backoff > t.breaker.MaxBackoff {
+			backoff = t.breaker.MaxBackoff
+		}
+		t.open(url, state, backoff)
+	case state.circuit == CircuitClosed && state.consecutiveFailures >= t.breaker.FailureThreshold:
+		t.open(url, state, t.breaker.Backoff)
+	}
+}
+
+// recordSuccess closes the circuit of a peer that answered; the lock must be held
+func (t *peerTracker) recordSuccess(url string, state *peerState) {
+	state.consecutiveFailures = 0
+	if state.circuit != CircuitClosed {
+		state.circuit = CircuitClosed
+		state.probeAt = time.Time{}
+		log.Infof("Peer %s back in selection", url)
+	}
+}
+
+// open takes a peer out of selection for backoff; the lock must be held
+func (t *peerTracker) open(url string, state *peerState, backoff time.Duration) {
+	state.circuit = CircuitOpen
+	state.backoff = backoff
+	state.ejections++
+	state.ejectedUntil = t.now().Add(backoff)
+	state.probeAt = time.Time{}
+	log.Warnf("Peer %s out of selection for %s after %d consecutive failure(s), last error: %s", url, backoff, state.consecutiveFailures, state.lastError)
+}
+
+// admits tells whether a peer may be selected: its circuit is closed, or its backoff elapsed and no probe is in flight.
+// A probe lost without outcome, eg. because the call was abandoned before reaching the peer, is given up after the backoff.
+// The lock must be held.
+func (t *peerTracker) admits(state *peerState) bool {
+	now := t.now()
+	switch state.circuit {
+	case CircuitOpen:
+		return !now.Before(state.ejectedUntil)
+	case CircuitHalfOpen:
+		return !now.Before(state.probeAt.Add(state.backoff))
+	default:
+		return true
+	}
+}
+
+// admitted returns the indexes of the candidates whose peers may all be selected
+func (t *peerTracker) admitted(candidates [][]fab.Peer) []int {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	var indexes []int
+	for i, peers := range candidates {
+		admitted := true
+		for _, peer := range peers {
+			if !t.admits(t.state(peer.URL())) {
+				admitted = false
+				break
+			}
+		}
+		if admitted {
+			indexes = append(indexes, i)
+		}
+	}
+	return indexes
+}
+
+// selected marks the peers whose backoff elapsed as probed by the proposal they were selected for
+func (t *peerTracker) selected(peers []fab.Peer) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	for _, peer := range peers {
+		state := t.state(peer.URL())
+		if state.circuit != CircuitClosed && t.admits(state) {
+			state.circuit = CircuitHalfOpen
+			state.probeAt = t.now()
+			log.Infof("Probing peer %s", peer.URL())
+		}
+	}
+}
+
+// selectCandidate chooses a candidate with a strategy, among those whose peers are not out of selection.
+// If all candidates have a peer out of selection, any of them is better than none.
+func selectCandidate(s strategy, candidates [][]fab.Peer, mspID string) int {
+	indexes := tracker.admitted(candidates)
+	if len(indexes) == 0 {
+		log.Warnf("All %d candidate(s) have a peer out of selection, choosing among all of them", len(candidates))
+		indexes = make([]int, len(candidates))
+		for i := range candidates {
+			indexes[i] = i
+		}
+	}
+
+	admitted := make([][]fab.Peer, len(indexes))
+	for i, index := range indexes {
+		admitted[i] = candidates[index]
+	}
+	index := indexes[s.choose(admitted, mspID)]
+	tracker.selected(candidates[index])
+	return index
+}
@@ -6,6 +6,7 @@ SPDX-License-Identifier: Apache-2.0
 package factory
 
 import (
+	reqContext "context"
 	"sync"
 
 	"github.com/hyperledger/fabric-sdk-go/pkg/client/common/selection/dynamicselection"
@@ -18,6 +19,7 @@ type dynamicSelectionChannelProvider struct {
 	services map[string]*dynamicselection.SelectionService
 	lock     sync.RWMutex
 	config   fab.EndpointConfig
+	strategy strategy
 }
 
 // ChannelService creates a ChannelService
@@ -26,26 +28,30 @@ func (cp *dynamicSelectionChannelProvider) ChannelService(ctx fab.ClientContext,
 	if err != nil {
 		return nil, err
 	}
+	// the peer groups chosen depend on the organization of the client, eg. with the own_org_first strategy
+	mspID := ctx.Identifier().MSPID
+	key := channelID + "/" + mspID
 	cp.lock.RLock()
-	selection, ok := cp.services[channelID]
+	selection, ok := cp.services[key]
 	cp.lock.RUnlock()
 	if !ok {
 		discovery, err := chService.Discovery()
 		if err != nil {
 			return nil, err
 		}
-		selection, err = dynamicselection.NewService(ctx, channelID, discovery)
+		lbp := &strategyLBP{strategy: cp.strategy, mspID: mspID}
+		selection, err = dynamicselection.NewService(ctx, channelID, discovery, dynamicselection.WithLoadBalancePolicy(lbp))
 		if err != nil {
 			return nil, err
 		}
 		cp.lock.Lock()
-		cp.services[channelID] = selection
+		cp.services[key] = selection
 		cp.lock.Unlock()
 	}
 
 	return &dynamicSelectionChannelService{
 		ChannelService: chService,
-		selection:      selection,
+		selection:      &trackingSelectionService{SelectionService: selection, tracker: tracker},
 	}, nil
 }
 
@@ -58,6 +64,11 @@ func (cs *dynamicSelectionChannelService) Selection() (fab.SelectionService, err
 	return cs.selection, nil
 }
 
+// Transactor returns a transactor sending transactions to the orderer of reqCtx, if set with WithOrderer
+func (cs *dynamicSelectionChannelService) Transactor(reqCtx reqContext.Context) (fab.Transactor, error) {
+	return transactor(cs.ChannelService, reqCtx)
+}
+
 type initializer interface {
 	Initialize(providers context.Providers) error
 }
@@ -0,0 +1,64 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package factory
+
+import (
+	reqContext "context"
+	"fmt"
+
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/providers/fab"
+	contextImpl "github.com/hyperledger/fabric-sdk-go/pkg/context"
+	"github.com/hyperledger/fabric-sdk-go/pkg/fab/txn"
+)
+
+// ordererKey is the context key of the orderer transactions are sent to
+type ordererKey struct{}
+
+// WithOrderer returns a context in which the transactions of invocations are sent to the given orderer,
+// by name or URL as in the SDK configuration, instead of any orderer of the channel
+//
+func WithOrderer(ctx reqContext.Context, orderer string) reqContext.Context {
+	return reqContext.WithValue(ctx, ordererKey{}, orderer)
+}
+
+// transactor returns the transactor of a channel service, sending transactions to the orderer of reqCtx if it has one
+func transactor(chService fab.ChannelService, reqCtx reqContext.Context) (fab.Transactor, error) {
+	t, err := chService.Transactor(reqCtx)
+	if err != nil {
+		return nil, err
+	}
+	ordererName, ok := reqCtx.Value(ordererKey{}).(string)
+	if !ok || ordererName == "" {
+		return t, nil
+	}
+
+	ctx, ok := contextImpl.RequestClientContext(reqCtx)
+	if !ok {
+		return nil, fmt.Errorf("failed to get client context from request context")
+	}
+	ordererCfg, ok := ctx.EndpointConfig().OrdererConfig(ordererName)
+	if !ok {
+		return nil, fmt.Errorf("orderer %s not found in the SDK configuration", ordererName)
+	}
+	orderer, err := ctx.InfraProvider().CreateOrdererFromConfig(ordererCfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create orderer %s: %s", ordererName, err)
+	}
+	return &ordererTransactor{Transactor: t, reqCtx: reqCtx, orderer: orderer}, nil
+}
+
+// ordererTransactor is a transactor sending transactions to a given orderer
+type ordererTransactor struct {
+	fab.Transactor
+	reqCtx  reqContext.Context
+	orderer fab.Orderer
+}
+
+// SendTransaction sends a transaction to the orderer of the transactor
+func (t *ordererTransactor) SendTransaction(tx *fab.Transaction) (*fab.TransactionResponse, error) {
+	log.Debugf("Sending transaction to orderer %s", t.orderer.URL())
+	return txn.Send(t.reqCtx, tx, []fab.Orderer{t.orderer})
+}
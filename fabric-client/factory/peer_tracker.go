@@ -0,0 +1,197 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package factory
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	copts "github.com/hyperledger/fabric-sdk-go/pkg/common/options"
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/providers/fab"
+)
+
+// latencyDecay is the weight of the latest proposal latency in the moving average of a peer
+const latencyDecay = 0.2
+
+// tracker follows the proposals sent to the selected peers, for all service providers
+var tracker = newPeerTracker()
+
+// peerTracker tracks the proposals in progress, the moving average of the proposal latencies and the health of peers
+type peerTracker struct {
+	lock    sync.Mutex
+	peers   map[string]*peerState
+	breaker CircuitBreakerOptions
+	now     func() time.Time
+}
+
+type peerState struct {
+	outstanding   int
+	latencyMillis float64 // exponentially weighted moving average, zero until a proposal completes
+
+	proposals           int
+	failures            int
+	consecutiveFailures int
+	lastError           string
+	lastErrorAt         time.Time
+
+	circuit      string
+	backoff      time.Duration // backoff of the latest ejection
+	ejections    int
+	ejectedUntil time.Time
+	probeAt      time.Time // when the probe in flight was selected, zero if none
+}
+
+func newPeerTracker() *peerTracker {
+	return &peerTracker{
+		peers:   make(map[string]*peerState),
+		breaker: DefaultCircuitBreakerOptions,
+		now:     time.Now,
+	}
+}
+
+// state returns the state of a peer, the lock must be held
+func (t *peerTracker) state(url string) *peerState {
+	state, ok := t.peers[url]
+	if !ok {
+		state = &peerState{circuit: CircuitClosed}
+		t.peers[url] = state
+	}
+	return state
+}
+
+func (t *peerTracker) outstanding(url string) int {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return t.state(url).outstanding
+}
+
+func (t *peerTracker) latencyMillis(url string) float64 {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return t.state(url).latencyMillis
+}
+
+func (t *peerTracker) start(url string) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	state := t.state(url)
+	state.outstanding++
+	state.proposals++
+}
+
+// done records the outcome of a proposal; err is nil if the peer answered, even with a chaincode error
+func (t *peerTracker) done(url string, latency time.Duration, err error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	state := t.state(url)
+	state.outstanding--
+	if err != nil {
+		t.recordFailure(url, state, err)
+		return
+	}
+
+	millis := float64(latency) / float64(time.Millisecond)
+	if state.latencyMillis == 0 {
+		state.latencyMillis = millis
+	} else {
+		state.latencyMillis = latencyDecay*millis + (1-latencyDecay)*state.latencyMillis
+	}
+	t.recordSuccess(url, state)
+}
+
+// trackedPeer is a peer whose proposals are tracked
+type trackedPeer struct {
+	fab.Peer
+	tracker *peerTracker
+}
+
+// ProcessTransactionProposal sends a proposal to the peer, tracking it
+func (p *trackedPeer) ProcessTransactionProposal(ctx context.Context, request fab.ProcessProposalRequest) (*fab.TransactionProposalResponse, error) {
+	url := p.URL()
+	p.tracker.start(url)
+	start := time.Now()
+	resp, err := p.Peer.ProcessTransactionProposal(ctx, request)
+	p.tracker.done(url, time.Since(start), peerFailure(ctx, err))
+	return resp, err
+}
+
+// trackPeers returns the peers with their proposals tracked
+func (t *peerTracker) trackPeers(peers []fab.Peer) []fab.Peer {
+	tracked := make([]fab.Peer, len(peers))
+	for i, peer := range peers {
+		if _, ok := peer.(*trackedPeer); ok {
+			tracked[i] = peer
+			continue
+		}
+		tracked[i] = &trackedPeer{Peer: peer, tracker: t}
+	}
+	return tracked
+}
+
+// PeerHealth is the health of a peer as tracked by selection
+type PeerHealth struct {
+	Peer                string
+	Circuit             string // CircuitClosed, CircuitOpen or CircuitHalfOpen
+	Outstanding         int
+	LatencyMillis       float64 // moving average of the proposal latencies
+	Proposals           int
+	Failures            int
+	ConsecutiveFailures int
+	LastError           string
+	LastErrorAt         time.Time
+	Ejections           int
+	EjectedUntil        time.Time // zero unless the circuit is open
+}
+
+// PeerHealthStates returns the health of the peers selected so far, by peer URL
+//
+func PeerHealthStates() []PeerHealth {
+	return tracker.health()
+}
+
+func (t *peerTracker) health() []PeerHealth {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	var peers []PeerHealth
+	for url, state := range t.peers {
+		health := PeerHealth{
+			Peer:                url,
+			Circuit:             state.circuit,
+			Outstanding:         state.outstanding,
+			LatencyMillis:       state.latencyMillis,
+			Proposals:           state.proposals,
+			Failures:            state.failures,
+			ConsecutiveFailures: state.consecutiveFailures,
+			LastError:           state.lastError,
+			LastErrorAt:         state.lastErrorAt,
+			Ejections:           state.ejections,
+		}
+		if state.circuit == CircuitOpen {
+			health.EjectedUntil = state.ejectedUntil
+		}
+		peers = append(peers, health)
+	}
+	sort.Slice(peers, func(i, j int) bool { return peers[i].Peer < peers[j].Peer })
+	return peers
+}
+
+// trackingSelectionService is a selection service whose selected peers have their proposals tracked
+type trackingSelectionService struct {
+	fab.SelectionService
+	tracker *peerTracker
+}
+
+// GetEndorsersForChaincode returns the peers selected by the underlying service, tracked
+func (s *trackingSelectionService) GetEndorsersForChaincode(chaincodes []*fab.ChaincodeCall, opts ...copts.Opt) ([]fab.Peer, error) {
+	peers, err := s.SelectionService.GetEndorsersForChaincode(chaincodes, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return s.tracker.trackPeers(peers), nil
+}
@@ -0,0 +1,197 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package factory
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+	"sync"
+
+	"github.com/hyperledger/fabric-sdk-go/pkg/client/common/selection/dynamicselection/pgresolver"
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/providers/fab"
+)
+
+// Selection strategies, choosing among the peers (or the groups of peers satisfying the endorsement policy) eligible for a call
+const (
+	StrategyRandom           = "random"
+	StrategyRoundRobin       = "round_robin"
+	StrategyLeastOutstanding = "least_outstanding" // fewest proposals in progress
+	StrategyLowestLatency    = "lowest_latency"    // lowest moving average of proposal latencies
+	StrategyWeighted         = "weighted"          // random, in proportion to the configured peer weights
+	StrategyOwnOrgFirst      = "own_org_first"     // peers of the client organization, others if none is eligible
+)
+
+// Strategies are the available selection strategies
+var Strategies = []string{StrategyRandom, StrategyRoundRobin, StrategyLeastOutstanding, StrategyLowestLatency, StrategyWeighted, StrategyOwnOrgFirst}
+
+// SelectionOptions configure how a service provider factory selects peers
+type SelectionOptions struct {
+	Strategy string         // one of Strategies, random if empty
+	Weights  map[string]int // weights of the peers by URL for the weighted strategy, peers not listed weigh 1
+}
+
+// strategy chooses one of the candidate groups of peers, given the MSP of the client.
+// Queries have single peer candidates, invocations the groups satisfying the endorsement policy.
+type strategy interface {
+	choose(candidates [][]fab.Peer, mspID string) int
+}
+
+// newStrategy returns the strategy selected by opts
+func newStrategy(opts SelectionOptions) (strategy, error) {
+	switch strings.ToLower(opts.Strategy) {
+	case "", StrategyRandom:
+		return randomStrategy{}, nil
+	case StrategyRoundRobin:
+		return &roundRobinStrategy{}, nil
+	case StrategyLeastOutstanding:
+		return lowestScoreStrategy{score: func(peer fab.Peer) float64 { return float64(tracker.outstanding(peer.URL())) }, sum: true}, nil
+	case StrategyLowestLatency:
+		// endorsements are requested in parallel, so a group is as slow as its slowest peer
+		return lowestScoreStrategy{score: func(peer fab.Peer) float64 { return tracker.latencyMillis(peer.URL()) }}, nil
+	case StrategyWeighted:
+		for url, weight := range opts.Weights {
+			if weight < 0 {
+				return nil, fmt.Errorf("weight of peer %s must not be negative", url)
+			}
+		}
+		return weightedStrategy{weights: opts.Weights}, nil
+	case StrategyOwnOrgFirst:
+		return ownOrgFirstStrategy{}, nil
+	default:
+		return nil, fmt.Errorf("unknown selection strategy: %s, available strategies are %s", opts.Strategy, strings.Join(Strategies, ", "))
+	}
+}
+
+type randomStrategy struct{}
+
+func (randomStrategy) choose(candidates [][]fab.Peer, mspID string) int {
+	return rand.Intn(len(candidates))
+}
+
+type roundRobinStrategy struct {
+	lock sync.Mutex
+	next int
+}
+
+func (s *roundRobinStrategy) choose(candidates [][]fab.Peer, mspID string) int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	index := s.next % len(candidates)
+	s.next = index + 1
+	return index
+}
+
+// lowestScoreStrategy chooses the candidate with the lowest score, the maximum (or the sum) of the scores of its peers.
+// Ties are broken randomly so that peers without history get their share.
+type lowestScoreStrategy struct {
+	score func(peer fab.Peer) float64
+	sum   bool
+}
+
+func (s lowestScoreStrategy) choose(candidates [][]fab.Peer, mspID string) int {
+	var lowest []int
+	lowestScore := math.Inf(1)
+	for i, peers := range candidates {
+		var score float64
+		for _, peer := range peers {
+			if peerScore := s.score(peer); s.sum {
+				score += peerScore
+			} else if peerScore > score {
+				score = peerScore
+			}
+		}
+		if score < lowestScore {
+			lowest, lowestScore = []int{i}, score
+		} else if score == lowestScore {
+			lowest = append(lowest, i)
+		}
+	}
+	return lowest[rand.Intn(len(lowest))]
+}
+
+// weightedStrategy chooses randomly in proportion to the weight of the candidates, the lowest weight of their peers
+type weightedStrategy struct {
+	weights map[string]int
+}
+
+func (s weightedStrategy) weight(peers []fab.Peer) int {
+	weight := -1
+	for _, peer := range peers {
+		peerWeight, ok := s.weights[peer.URL()]
+		if !ok {
+			peerWeight = 1
+		}
+		if weight < 0 || peerWeight < weight {
+			weight = peerWeight
+		}
+	}
+	return weight
+}
+
+func (s weightedStrategy) choose(candidates [][]fab.Peer, mspID string) int {
+	weights := make([]int, len(candidates))
+	total := 0
+	for i, peers := range candidates {
+		if weights[i] = s.weight(peers); weights[i] > 0 {
+			total += weights[i]
+		}
+	}
+	if total == 0 {
+		// all candidates are weighted out, better any peer than none
+		return rand.Intn(len(candidates))
+	}
+
+	n := rand.Intn(total)
+	for i, weight := range weights {
+		if weight <= 0 {
+			continue
+		}
+		if n < weight {
+			return i
+		}
+		n -= weight
+	}
+	return len(candidates) - 1
+}
+
+// ownOrgFirstStrategy chooses randomly among the candidates with the fewest peers outside the client organization
+type ownOrgFirstStrategy struct{}
+
+func (ownOrgFirstStrategy) choose(candidates [][]fab.Peer, mspID string) int {
+	return lowestScoreStrategy{
+		score: func(peer fab.Peer) float64 {
+			if peer.MSPID() == mspID {
+				return 0
+			}
+			return 1
+		},
+		sum: true,
+	}.choose(candidates, mspID)
+}
+
+// strategyLBP is a dynamic selection load balance policy choosing peer groups with a strategy
+type strategyLBP struct {
+	strategy strategy
+	mspID    string
+}
+
+// Choose implements pgresolver.LoadBalancePolicy
+func (p *strategyLBP) Choose(peerGroups []pgresolver.PeerGroup) pgresolver.PeerGroup {
+	if len(peerGroups) == 0 {
+		log.Warnf("No available peer groups")
+		return pgresolver.NewPeerGroup()
+	}
+	candidates := make([][]fab.Peer, len(peerGroups))
+	for i, group := range peerGroups {
+		candidates[i] = group.Peers()
+	}
+	index := selectCandidate(p.strategy, candidates, p.mspID)
+
+	log.Debugf("Choosing peer group %d out of %d peer group(s)", index, len(peerGroups))
+	return peerGroups[index]
+}
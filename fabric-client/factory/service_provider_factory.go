@@ -17,24 +17,36 @@ import (
 type MPerfQueryServiceProviderFactory struct {
 	defsvc.ProviderFactory
 	IsPeerFilterMandatory bool
+	strategy              strategy
 }
 
 // MPerfServiceProviderFactory A fabric-sdk service provider factory customized to Marbles needs.
 // In particular, the selection provider created by this factory is a Dynamic Selection Provider
 type MPerfServiceProviderFactory struct {
 	defsvc.ProviderFactory
-	userID string
-	org    string
+	userID   string
+	org      string
+	strategy strategy
 }
 
-// NewMPerfQueryServiceProviderFactory create a new instance of MPerfQueryServiceProviderFactory
-func NewMPerfQueryServiceProviderFactory(isPeerFilterMandatory bool) *MPerfQueryServiceProviderFactory {
-	return &MPerfQueryServiceProviderFactory{IsPeerFilterMandatory: isPeerFilterMandatory}
+// NewMPerfQueryServiceProviderFactory create a new instance of MPerfQueryServiceProviderFactory,
+// choosing the peer to query with the strategy of selection
+func NewMPerfQueryServiceProviderFactory(isPeerFilterMandatory bool, selection SelectionOptions) (*MPerfQueryServiceProviderFactory, error) {
+	strategy, err := newStrategy(selection)
+	if err != nil {
+		return nil, err
+	}
+	return &MPerfQueryServiceProviderFactory{IsPeerFilterMandatory: isPeerFilterMandatory, strategy: strategy}, nil
 }
 
-// NewMPerfServiceProviderFactory create a new instance of MPerfServiceProviderFactory
-func NewMPerfServiceProviderFactory(userID string) *MPerfServiceProviderFactory {
-	return &MPerfServiceProviderFactory{userID: userID}
+// NewMPerfServiceProviderFactory create a new instance of MPerfServiceProviderFactory,
+// choosing among the peer groups satisfying endorsement policies with the strategy of selection
+func NewMPerfServiceProviderFactory(userID string, selection SelectionOptions) (*MPerfServiceProviderFactory, error) {
+	strategy, err := newStrategy(selection)
+	if err != nil {
+		return nil, err
+	}
+	return &MPerfServiceProviderFactory{userID: userID, strategy: strategy}, nil
 }
 
 // CreateChannelProvider return a new implementation of OneOfSelectionProvider
@@ -46,6 +58,7 @@ func (f *MPerfQueryServiceProviderFactory) CreateChannelProvider(config fab.Endp
 	return &OneOfSelectionProvider{
 		ChannelProvider:       chProvider,
 		IsPeerFilterMandatory: f.IsPeerFilterMandatory,
+		strategy:              f.strategy,
 	}, nil
 }
 
@@ -59,5 +72,6 @@ func (f *MPerfServiceProviderFactory) CreateChannelProvider(config fab.EndpointC
 		ChannelProvider: chProvider,
 		services:        make(map[string]*dynamicselection.SelectionService),
 		config:          config,
+		strategy:        f.strategy,
 	}, nil
 }
@@ -23,11 +23,12 @@ func init() {
 	rand.Seed(int64(time.Now().Nanosecond()))
 }
 
-// OneOfSelectionProvider implements a selection provider that randomly chooses
-// a peer from the list of available peers
+// OneOfSelectionProvider implements a selection provider that chooses
+// a peer from the list of available peers, randomly unless another strategy is set
 type OneOfSelectionProvider struct {
 	fab.ChannelProvider
 	IsPeerFilterMandatory bool
+	strategy              strategy
 }
 
 // Initialize sets the provider context
@@ -49,10 +50,16 @@ func (cp *OneOfSelectionProvider) ChannelService(ctx fab.ClientContext, channelI
 		return nil, err
 	}
 
+	strategy := cp.strategy
+	if strategy == nil {
+		strategy = randomStrategy{}
+	}
 	return &staticSelectionChannelService{
 		ChannelService:        chService,
 		IsPeerFilterMandatory: cp.IsPeerFilterMandatory,
 		discoveryService:      discovery,
+		strategy:              strategy,
+		mspID:                 ctx.Identifier().MSPID,
 	}, nil
 }
 
@@ -60,13 +67,20 @@ type staticSelectionChannelService struct {
 	fab.ChannelService
 	IsPeerFilterMandatory bool
 	discoveryService      fab.DiscoveryService
+	strategy              strategy
+	mspID                 string
 }
 
 // CreateSelectionService creates a static selection service
 func (p *staticSelectionChannelService) Selection() (fab.SelectionService, error) {
-	return &service{
-		discoveryService:      p.discoveryService,
-		isPeerFilterMandatory: p.IsPeerFilterMandatory,
+	return &trackingSelectionService{
+		SelectionService: &service{
+			discoveryService:      p.discoveryService,
+			isPeerFilterMandatory: p.IsPeerFilterMandatory,
+			strategy:              p.strategy,
+			mspID:                 p.mspID,
+		},
+		tracker: tracker,
 	}, nil
 }
 
@@ -78,9 +92,11 @@ func (p *staticSelectionChannelService) Selection() (fab.SelectionService, error
 type service struct {
 	discoveryService      fab.DiscoveryService
 	isPeerFilterMandatory bool
+	strategy              strategy
+	mspID                 string // MSP of the client
 }
 
-// GetEndorsersForChaincode returns a random peer from the list of peers
+// GetEndorsersForChaincode returns a peer from the list of peers, chosen by the selection strategy
 func (s *service) GetEndorsersForChaincode(chaincodes []*fab.ChaincodeCall, opts ...copts.Opt) ([]fab.Peer, error) {
 	if s == nil {
 		return nil, fmt.Errorf("service: s is nil")
@@ -123,8 +139,11 @@ func (s *service) GetEndorsersForChaincode(chaincodes []*fab.ChaincodeCall, opts
 		return nil, nil
 	}
 
-	// Choose a random peer
-	index := rand.Intn(len(peers))
+	candidates := make([][]fab.Peer, len(peers))
+	for i, peer := range peers {
+		candidates[i] = []fab.Peer{peer}
+	}
+	index := selectCandidate(s.strategy, candidates, s.mspID)
 
 	log.Debugf("Choosing peer %s out of %d peer(s)", peers[index].URL(), len(peers))
 	return []fab.Peer{peers[index]}, nil
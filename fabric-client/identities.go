@@ -0,0 +1,137 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package fabricclient
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric-sdk-go/pkg/client/msp"
+	"github.com/hyperledger/fabric-sdk-go/pkg/fabsdk"
+	"github.com/spf13/viper"
+)
+
+const (
+	configIdentityUsers             = configTreeNameFabricSDK + ".identities.users"
+	configIdentityGeneratePrefix    = configTreeNameFabricSDK + ".identities.generate.prefix"
+	configIdentityGenerateCount     = configTreeNameFabricSDK + ".identities.generate.count"
+	configIdentityGenerateSecret    = configTreeNameFabricSDK + ".identities.generate.enroll_secret"
+	configIdentityGenerateRegister  = configTreeNameFabricSDK + ".identities.generate.register"
+	configIdentityGenerateAffiliate = configTreeNameFabricSDK + ".identities.generate.affiliation"
+)
+
+// identityConfig is a user configured under fabric_sdk.identities.users
+type identityConfig struct {
+	ID           string `mapstructure:"id"`
+	EnrollSecret string `mapstructure:"enroll_secret"`
+}
+
+// initIdentities enrolls the users configured in addition to the default user, if not enrolled yet.
+// Generated users are registered first if requested, with the registrar of the CA in the SDK configuration.
+//
+func (t *fabClient) initIdentities() error {
+	t.identities = []string{t.userID}
+	t.knownIdentities = map[string]bool{t.userID: true}
+
+	var users []identityConfig
+	if err := viper.UnmarshalKey(configIdentityUsers, &users); err != nil {
+		return fmt.Errorf("configuration error, invalid %s: %s", configIdentityUsers, err)
+	}
+	for _, user := range users {
+		if user.ID == "" {
+			return fmt.Errorf("configuration error, user without id under %s", configIdentityUsers)
+		}
+		if err := enrollMember(t.fabricSDK, "", user.ID, user.EnrollSecret); err != nil {
+			return fmt.Errorf("failed to enroll member %s: %v", user.ID, err)
+		}
+		t.addIdentity(user.ID)
+	}
+
+	count := viper.GetInt(configIdentityGenerateCount)
+	if count <= 0 {
+		return nil
+	}
+	prefix := viper.GetString(configIdentityGeneratePrefix)
+	if prefix == "" {
+		return fmt.Errorf("configuration error, %s not set", configIdentityGeneratePrefix)
+	}
+	secret := viper.GetString(configIdentityGenerateSecret)
+	for i := 1; i <= count; i++ {
+		userID := fmt.Sprintf("%s%d", prefix, i)
+		if viper.GetBool(configIdentityGenerateRegister) {
+			if err := registerMemberIfNecessary(t.fabricSDK, userID, secret, viper.GetString(configIdentityGenerateAffiliate)); err != nil {
+				return fmt.Errorf("failed to register member %s: %v", userID, err)
+			}
+		}
+		if err := enrollMember(t.fabricSDK, "", userID, secret); err != nil {
+			return fmt.Errorf("failed to enroll member %s: %v", userID, err)
+		}
+		t.addIdentity(userID)
+	}
+
+	logger.Infof("transacting as %d identities", len(t.identities))
+	return nil
+}
+
+func (t *fabClient) addIdentity(userID string) {
+	if !t.knownIdentities[userID] {
+		t.knownIdentities[userID] = true
+		t.identities = append(t.identities, userID)
+	}
+}
+
+// Identities returns the users the client can transact as, the default user first
+//
+func (t *fabClient) Identities() []string {
+	return append([]string(nil), t.identities...)
+}
+
+// identity returns the organization and the user a call is made as.
+// Identities other than the default user belong to the default organization, other organizations have a single user.
+//
+func (t *fabClient) identity(o *callOptions) (orgName string, userID string, err error) {
+	if o.org != "" && o.org != t.orgName {
+		userID, exists := t.orgUsers[o.org]
+		if !exists {
+			return "", "", fmt.Errorf("unknown organization %s, organizations are configured under %s", o.org, configOrganizations)
+		}
+		if o.identity != "" && o.identity != userID {
+			return "", "", fmt.Errorf("identity %s is not configured for organization %s", o.identity, o.org)
+		}
+		return o.org, userID, nil
+	}
+
+	if o.identity == "" {
+		return t.orgName, t.userID, nil
+	}
+	if !t.knownIdentities[o.identity] {
+		return "", "", fmt.Errorf("unknown identity %s, identities are configured under %s", o.identity, configTreeNameFabricSDK+".identities")
+	}
+	return t.orgName, o.identity, nil
+}
+
+// registerMemberIfNecessary registers a user with the CA, a user registered already being left as is
+func registerMemberIfNecessary(fabricSDK *fabsdk.FabricSDK, userID, secret, affiliation string) error {
+	mspClient, err := msp.New(fabricSDK.Context())
+	if err != nil {
+		return fmt.Errorf("failed to create mspClient: %v", err)
+	}
+	if user, err := mspClient.GetSigningIdentity(userID); user != nil && err == nil {
+		// enrolled already
+		return nil
+	}
+
+	_, err = mspClient.Register(&msp.RegistrationRequest{
+		Name:        userID,
+		Type:        "client",
+		Affiliation: affiliation,
+		Secret:      secret,
+	})
+	if err != nil && !strings.Contains(err.Error(), "already registered") {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package fabricclient
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/hyperledger/fabric-sdk-go/pkg/client/ledger"
+	fabapi "github.com/hyperledger/fabric-sdk-go/pkg/common/providers/fab"
+)
+
+// PeerLedgerInfo is the ledger of a channel as reported by one of its peers
+type PeerLedgerInfo struct {
+	Peer             string
+	Height           uint64
+	CurrentBlockHash []byte
+	Err              error // failure to query the peer, the other fields are not set
+}
+
+// LedgerInfo returns the height and current block hash of the ledger of a channel at each of its peers, sorted by peer URL
+//
+func (t *fabClient) LedgerInfo(channelID string) ([]PeerLedgerInfo, error) {
+	chProvider := t.NewChannelProvider(channelID)
+	peers, err := discoverPeers(chProvider, channelID)
+	if err != nil {
+		return nil, err
+	}
+	ledgerClient, err := ledger.New(chProvider)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create ledger client for channel %s: %s", channelID, err)
+	}
+
+	infos := make([]PeerLedgerInfo, len(peers))
+	var wg sync.WaitGroup
+	for i := range peers {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			infos[i].Peer = peers[i].URL()
+			// the ledger client reports the highest of the peers queried, hence each peer is queried on its own
+			resp, err := ledgerClient.QueryInfo(ledger.WithTargets(peers[i]))
+			if err != nil {
+				infos[i].Err = fmt.Errorf("failed to query info of channel %s at %s: %s", channelID, infos[i].Peer, err)
+				return
+			}
+			infos[i].Height = resp.BCI.Height
+			infos[i].CurrentBlockHash = resp.BCI.CurrentBlockHash
+		}(i)
+	}
+	wg.Wait()
+
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Peer < infos[j].Peer })
+	return infos, nil
+}
+
+// TransactionCommitted tells whether a transaction is committed in the ledger of a channel at each of the given peers,
+// by URL as in the SDK configuration. A peer that fails to answer is reported as not having the transaction.
+//
+func (t *fabClient) TransactionCommitted(channelID string, txID string, peerURLs []string) ([]bool, error) {
+	shard := t.pool.shards[0]
+	ledgerClient, err := shard.ledgerClient(channelID, t.orgName, t.userID)
+	if err != nil {
+		return nil, err
+	}
+	peers := make([]fabapi.Peer, len(peerURLs))
+	for i, peerURL := range peerURLs {
+		if peers[i], err = shard.peer(false, channelID, t.orgName, t.userID, peerURL); err != nil {
+			return nil, err
+		}
+	}
+
+	committed := make([]bool, len(peers))
+	var wg sync.WaitGroup
+	for i := range peers {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, err := ledgerClient.QueryTransaction(fabapi.TransactionID(txID), ledger.WithTargets(peers[i]))
+			committed[i] = err == nil
+		}(i)
+	}
+	wg.Wait()
+	return committed, nil
+}
@@ -6,78 +6,106 @@ package fabricclient
 
 import (
 	"github.com/hyperledger/fabric-sdk-go/pkg/core/logging/api"
+	"github.com/hyperledger/fabric-sdk-go/pkg/core/logging/modlog"
 	"github.com/op/go-logging"
+	"github.com/securekey/marbles-perf/utils"
 )
 
 // sdkLoggerProvider a logger provider that implements api.LoggerProvider interface in fabric-sdk
-type sdkLoggerProvider struct{ log *logging.Logger }
+type sdkLoggerProvider struct{}
 
 // sdkLogger is a logger that implements api.Logger interface in fabric-sdk
 type sdkLogger struct{ logging.Logger }
 
-// GetLogger is an implementation of api.LoggerProvider GetLogger
+// GetLogger is an implementation of api.LoggerProvider GetLogger,
+// fabric-sdk modules get a go-logging logger of the same name so that module levels apply to them
 func (p *sdkLoggerProvider) GetLogger(module string) api.Logger {
-	return &sdkLogger{*p.log}
+	return &sdkLogger{*logging.MustGetLogger(module)}
+}
+
+// initSDKLogging makes fabric-sdk log through go-logging, following the configured module levels.
+// fabric-sdk keeps levels of its own, which it checks before building expensive debug messages.
+func initSDKLogging() {
+	modlog.InitLogger(&sdkLoggerProvider{})
+	utils.OnLevelChange(func(module string, level logging.Level) {
+		modlog.SetLevel(module, sdkLevel(level))
+	})
+}
+
+// sdkLevel returns the fabric-sdk level of a go-logging level, fabric-sdk having no NOTICE level
+func sdkLevel(level logging.Level) api.Level {
+	switch level {
+	case logging.CRITICAL:
+		return api.CRITICAL
+	case logging.ERROR:
+		return api.ERROR
+	case logging.WARNING:
+		return api.WARNING
+	case logging.NOTICE, logging.INFO:
+		return api.INFO
+	default:
+		return api.DEBUG
+	}
 }
 
 // Fatalln is an implementation of api.Logger Fataln
 func (l *sdkLogger) Fatalln(v ...interface{}) {
 	v = append(v, "\n")
-	l.Fatal(v)
+	l.Fatal(v...)
 }
 
 // Panicln is an implementation of api.Logger Panicln
 func (l *sdkLogger) Panicln(v ...interface{}) {
 	v = append(v, "\n")
-	l.Panic(v)
+	l.Panic(v...)
 }
 
 // Print is an implementation of api.Logger Print
 func (l *sdkLogger) Print(v ...interface{}) {
-	l.Info(v)
+	l.Info(v...)
 }
 
 // Println is an implementation of api.Logger Println
 func (l *sdkLogger) Println(v ...interface{}) {
 	v = append(v, "\n")
-	l.Print(v)
+	l.Print(v...)
 }
 
 // Printf is an implementation of api.Logger Printf
 func (l *sdkLogger) Printf(format string, v ...interface{}) {
-	l.Infof(format, v)
+	l.Infof(format, v...)
 }
 
 // Debugln is an implementation of api.Logger Debugln
 func (l *sdkLogger) Debugln(v ...interface{}) {
 	v = append(v, "\n")
-	l.Debug(v)
+	l.Debug(v...)
 }
 
 // Infoln is an implementation of api.Logger Infoln
 func (l *sdkLogger) Infoln(v ...interface{}) {
 	v = append(v, "\n")
-	l.Info(v)
+	l.Info(v...)
 }
 
 // Warn is an implementation of api.Logger Warn
 func (l *sdkLogger) Warn(v ...interface{}) {
-	l.Warning(v)
+	l.Warning(v...)
 }
 
 // Warnln is an implementation of api.Logger Warnln
 func (l *sdkLogger) Warnln(v ...interface{}) {
 	v = append(v, "\n")
-	l.Warn(v)
+	l.Warn(v...)
 }
 
 // Warnf is an implementation of api.Logger Warnf
 func (l *sdkLogger) Warnf(format string, v ...interface{}) {
-	l.Warningf(format, v)
+	l.Warningf(format, v...)
 }
 
 // Errorln is an implementation of api.Logger Errorln
 func (l *sdkLogger) Errorln(v ...interface{}) {
 	v = append(v, "\n")
-	l.Error(v)
+	l.Error(v...)
 }
@@ -0,0 +1,141 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package fabricclient
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	metricsNamespace = "marbles_perf"
+	metricsSubsystem = "fabric"
+
+	operationInvoke = "invoke"
+	operationQuery  = "query"
+
+	resultSuccess = "success"
+	resultFailure = "failure"
+)
+
+var (
+	ccDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "cc_duration_seconds",
+			Help:      "Latency of chaincode invocations and queries, including retries.",
+			Buckets:   []float64{.05, .1, .25, .5, 1, 2, 3, 5, 10, 20, 30, 60},
+		},
+		[]string{"operation", "function"},
+	)
+
+	ccRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "cc_requests_total",
+			Help:      "Number of chaincode invocations and queries by result.",
+		},
+		[]string{"operation", "function", "result"},
+	)
+
+	ccRetries = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "cc_retries_total",
+			Help:      "Number of retries of chaincode invocations and queries.",
+		},
+		[]string{"operation", "function"},
+	)
+
+	ccInFlight = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "cc_in_flight",
+			Help:      "Number of chaincode invocations and queries in progress.",
+		},
+		[]string{"operation"},
+	)
+
+	shardRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "shard_requests_total",
+			Help:      "Number of chaincode invocations and queries by SDK instance of the pool.",
+		},
+		[]string{"shard", "operation"},
+	)
+
+	shardInFlight = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "shard_in_flight",
+			Help:      "Number of chaincode invocations and queries in progress by SDK instance of the pool.",
+		},
+		[]string{"shard", "operation"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(ccDuration, ccRequests, ccRetries, ccInFlight, shardRequests, shardInFlight)
+}
+
+// startShardCall records a call made with a shard of the SDK pool, the returned function must be called once it completes
+func startShardCall(shard *sdkShard, operation string) func() {
+	shardRequests.WithLabelValues(shard.id, operation).Inc()
+	inFlight := shardInFlight.WithLabelValues(shard.id, operation)
+	inFlight.Inc()
+	return inFlight.Dec
+}
+
+// ccCallMetrics records the metrics of a single chaincode invocation or query
+type ccCallMetrics struct {
+	operation string
+	function  string
+	start     time.Time
+	retries   []Retry
+}
+
+func startCCCallMetrics(operation string, args []string) *ccCallMetrics {
+	m := &ccCallMetrics{
+		operation: operation,
+		function:  extractFuncName(args),
+		start:     time.Now(),
+	}
+	ccInFlight.WithLabelValues(operation).Inc()
+	return m
+}
+
+// beforeRetry is a retry handler counting the retries of the call and recording their causes
+func (m *ccCallMetrics) beforeRetry(err error) {
+	ccRetries.WithLabelValues(m.operation, m.function).Inc()
+	m.retries = append(m.retries, newRetry(err))
+}
+
+// done records the outcome of the call
+func (m *ccCallMetrics) done(err error) {
+	ccInFlight.WithLabelValues(m.operation).Dec()
+	ccDuration.WithLabelValues(m.operation, m.function).Observe(time.Since(m.start).Seconds())
+
+	result := resultSuccess
+	if err != nil {
+		result = resultFailure
+	}
+	ccRequests.WithLabelValues(m.operation, m.function, result).Inc()
+}
+
+func extractFuncName(args []string) string {
+	if len(args) == 0 {
+		return ""
+	}
+	return args[0]
+}
@@ -0,0 +1,155 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package fabricclient
+
+import (
+	"context"
+	"time"
+
+	"github.com/hyperledger/fabric-sdk-go/pkg/client/channel"
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/errors/retry"
+	fabapi "github.com/hyperledger/fabric-sdk-go/pkg/common/providers/fab"
+)
+
+// CallOption customizes a chaincode invocation or query
+type CallOption func(*callOptions)
+
+type callOptions struct {
+	timeout     time.Duration
+	retryOpts   *retry.Opts
+	maxAttempts int
+	targets     []string
+	peerFilter  fabapi.TargetFilter
+	identity    string
+	org         string
+	shardKey    string
+	endorsers   []string
+	orderer     string
+	filterName  string
+}
+
+// WithTimeout sets the overall timeout of a call, retries included, instead of the execute timeout of the SDK configuration
+//
+func WithTimeout(timeout time.Duration) CallOption {
+	return func(o *callOptions) {
+		o.timeout = timeout
+	}
+}
+
+// WithRetry replaces the retry options configured for invocations or queries
+//
+func WithRetry(opts retry.Opts) CallOption {
+	return func(o *callOptions) {
+		o.retryOpts = &opts
+	}
+}
+
+// WithMaxAttempts sets the number of attempts of a call, overriding the attempts of the retry options
+//
+func WithMaxAttempts(maxAttempts int) CallOption {
+	return func(o *callOptions) {
+		o.maxAttempts = maxAttempts
+	}
+}
+
+// WithTargets sends a call to the given peers, by URL, instead of those picked by the SDK
+//
+func WithTargets(peerURLs ...string) CallOption {
+	return func(o *callOptions) {
+		o.targets = peerURLs
+	}
+}
+
+// WithPeerFilter restricts the peers a call can be sent to, see package peerfilter
+//
+func WithPeerFilter(filter fabapi.TargetFilter) CallOption {
+	return func(o *callOptions) {
+		o.peerFilter = filter
+	}
+}
+
+// WithNamedPeerFilter restricts the peers a call can be sent to with a filter declared under fabric_sdk.peer_filters, see Client.PeerFilter.
+// It combines with a filter set with WithPeerFilter, peers having to be accepted by both.
+//
+func WithNamedPeerFilter(name string) CallOption {
+	return func(o *callOptions) {
+		o.filterName = name
+	}
+}
+
+// WithIdentity makes a call as one of the users of Client.Identities instead of the default user
+//
+func WithIdentity(userID string) CallOption {
+	return func(o *callOptions) {
+		o.identity = userID
+	}
+}
+
+// WithOrg makes a call as the user of one of the organizations of Client.Orgs instead of the default organization
+//
+func WithOrg(orgName string) CallOption {
+	return func(o *callOptions) {
+		o.org = orgName
+	}
+}
+
+// WithEndorsers sends a call to the given peers of the channel, by URL as in the SDK configuration, instead of those picked by selection
+//
+func WithEndorsers(peerURLs ...string) CallOption {
+	return func(o *callOptions) {
+		o.endorsers = peerURLs
+	}
+}
+
+// WithOrderer sends the transaction of an invocation to the given orderer, by name or URL as in the SDK configuration,
+// instead of any orderer of the channel
+//
+func WithOrderer(orderer string) CallOption {
+	return func(o *callOptions) {
+		o.orderer = orderer
+	}
+}
+
+// WithShardKey makes calls with the same key use the same SDK instance of the pool, if the pool assigns them by worker hash
+//
+func WithShardKey(key string) CallOption {
+	return func(o *callOptions) {
+		o.shardKey = key
+	}
+}
+
+func newCallOptions(opts []CallOption) *callOptions {
+	o := &callOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// requestOptions returns the SDK request options of a call made within ctx, retryOpts being the configured retry options
+//
+func (o *callOptions) requestOptions(ctx context.Context, retryOpts retry.Opts, beforeRetry retry.BeforeRetryHandler) []channel.RequestOption {
+	if o.retryOpts != nil {
+		retryOpts = *o.retryOpts
+	}
+	if o.maxAttempts > 0 {
+		retryOpts.Attempts = o.maxAttempts
+	}
+
+	opts := []channel.RequestOption{
+		channel.WithParentContext(ctx),
+		channel.WithRetry(retryOpts),
+		channel.WithBeforeRetry(beforeRetry),
+	}
+	if o.timeout > 0 {
+		// the execute timeout bounds the request context of both invocations and queries
+		opts = append(opts, channel.WithTimeout(fabapi.Execute, o.timeout))
+	}
+	if o.peerFilter != nil {
+		opts = append(opts, channel.WithTargetFilter(o.peerFilter))
+	}
+	return opts
+}
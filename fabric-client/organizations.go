@@ -0,0 +1,57 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package fabricclient
+
+import (
+	"fmt"
+
+	"github.com/spf13/viper"
+)
+
+const configOrganizations = configTreeNameFabricSDK + ".organizations"
+
+// orgConfig is an organization configured under fabric_sdk.organizations, along with the user transacting for it
+type orgConfig struct {
+	Name         string `mapstructure:"name"`
+	UserID       string `mapstructure:"user_id"`
+	EnrollSecret string `mapstructure:"enroll_secret"`
+}
+
+// initOrganizations enrolls the users of the organizations configured in addition to the default organization.
+// The organizations must be defined in the SDK client configuration, along with their CAs.
+func (t *fabClient) initOrganizations() error {
+	t.orgs = []string{t.orgName}
+	t.orgUsers = make(map[string]string)
+
+	var orgs []orgConfig
+	if err := viper.UnmarshalKey(configOrganizations, &orgs); err != nil {
+		return fmt.Errorf("configuration error, invalid %s: %s", configOrganizations, err)
+	}
+	for _, org := range orgs {
+		if org.Name == "" || org.UserID == "" {
+			return fmt.Errorf("configuration error, organizations under %s need a name and a user_id", configOrganizations)
+		}
+		if _, exists := t.orgUsers[org.Name]; exists || org.Name == t.orgName {
+			return fmt.Errorf("configuration error, organization %s listed twice under %s", org.Name, configOrganizations)
+		}
+		if err := enrollMember(t.fabricSDK, org.Name, org.UserID, org.EnrollSecret); err != nil {
+			return fmt.Errorf("failed to enroll member %s of organization %s: %v", org.UserID, org.Name, err)
+		}
+		t.orgUsers[org.Name] = org.UserID
+		t.orgs = append(t.orgs, org.Name)
+	}
+
+	if len(t.orgs) > 1 {
+		logger.Infof("transacting as members of organizations %v", t.orgs)
+	}
+	return nil
+}
+
+// Orgs returns the organizations calls can be made as a member of, the default organization first
+//
+func (t *fabClient) Orgs() []string {
+	return append([]string(nil), t.orgs...)
+}
@@ -0,0 +1,65 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package fabricclient
+
+import (
+	"fmt"
+	"strings"
+
+	fabapi "github.com/hyperledger/fabric-sdk-go/pkg/common/providers/fab"
+	"github.com/securekey/marbles-perf/fabric-client/peerfilter"
+	"github.com/spf13/viper"
+)
+
+const (
+	configPeerFilters    = configTreeNameFabricSDK + ".peer_filters"
+	configPeerProperties = configTreeNameFabricSDK + ".peer_properties"
+)
+
+// initPeerFilters builds the peer filters declared under fabric_sdk.peer_filters, selecting peers by the properties
+// declared under fabric_sdk.peer_properties
+func (t *fabClient) initPeerFilters() error {
+	var specs map[string]peerfilter.Spec
+	if err := viper.UnmarshalKey(configPeerFilters, &specs); err != nil {
+		return fmt.Errorf("configuration error, invalid %s: %s", configPeerFilters, err)
+	}
+	var peerProperties []peerfilter.PeerProperties
+	if err := viper.UnmarshalKey(configPeerProperties, &peerProperties); err != nil {
+		return fmt.Errorf("configuration error, invalid %s: %s", configPeerProperties, err)
+	}
+	filters, err := peerfilter.NewNamedFilters(specs, peerProperties)
+	if err != nil {
+		return fmt.Errorf("configuration error, %s: %s", configPeerFilters, err)
+	}
+	t.peerFilters = filters
+	return nil
+}
+
+// PeerFilter returns a peer filter declared in configuration, by name
+//
+func (t *fabClient) PeerFilter(name string) (fabapi.TargetFilter, error) {
+	filter, ok := t.peerFilters[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("unknown peer filter: %s", name)
+	}
+	return filter, nil
+}
+
+// resolvePeerFilter sets the peer filter of a call made with WithNamedPeerFilter
+func (t *fabClient) resolvePeerFilter(o *callOptions) error {
+	if o.filterName == "" {
+		return nil
+	}
+	filter, err := t.PeerFilter(o.filterName)
+	if err != nil {
+		return err
+	}
+	if o.peerFilter != nil {
+		filter = peerfilter.AndFilter{Filters: []fabapi.TargetFilter{o.peerFilter, filter}}
+	}
+	o.peerFilter = filter
+	return nil
+}
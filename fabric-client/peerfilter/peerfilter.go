@@ -6,6 +6,9 @@ SPDX-License-Identifier: Apache-2.0
 package peerfilter
 
 import (
+	"regexp"
+	"strings"
+
 	"github.com/hyperledger/fabric-sdk-go/pkg/common/providers/fab"
 )
 
@@ -28,3 +31,94 @@ type URLFilter struct {
 func (filter URLFilter) Accept(peer fab.Peer) bool {
 	return filter.PeerURL == peer.URL()
 }
+
+// MSPSetFilter accept peers of any of the given MSP IDs
+type MSPSetFilter struct {
+	MSPIDs []string
+}
+
+// Accept ..
+func (filter MSPSetFilter) Accept(peer fab.Peer) bool {
+	for _, mspID := range filter.MSPIDs {
+		if mspID == peer.MSPID() {
+			return true
+		}
+	}
+	return false
+}
+
+// URLRegexpFilter accept peers whose URL matches the given regular expression
+type URLRegexpFilter struct {
+	Regexp *regexp.Regexp
+}
+
+// Accept ..
+func (filter URLRegexpFilter) Accept(peer fab.Peer) bool {
+	return filter.Regexp.MatchString(peer.URL())
+}
+
+// PropertyFilter accept peers having the given property with the given value. The SDK does not expose properties of peers,
+// hence they are looked up by peer URL in Properties, property names being lower case. Peers without properties are rejected.
+type PropertyFilter struct {
+	Name       string
+	Value      string
+	Properties map[string]map[string]string // properties of peers, by peer URL
+}
+
+// Accept ..
+func (filter PropertyFilter) Accept(peer fab.Peer) bool {
+	value, ok := filter.Properties[peer.URL()][strings.ToLower(filter.Name)]
+	return ok && value == filter.Value
+}
+
+// MinBlockHeightFilter accept peers whose ledger height is at least MinHeight.
+// Peers not reporting their ledger height are rejected.
+type MinBlockHeightFilter struct {
+	MinHeight uint64
+}
+
+// Accept ..
+func (filter MinBlockHeightFilter) Accept(peer fab.Peer) bool {
+	state, ok := peer.(fab.PeerState)
+	return ok && state.BlockHeight() >= filter.MinHeight
+}
+
+// AndFilter accept peers accepted by all of the given filters
+type AndFilter struct {
+	Filters []fab.TargetFilter
+}
+
+// Accept ..
+func (filter AndFilter) Accept(peer fab.Peer) bool {
+	for _, f := range filter.Filters {
+		if !f.Accept(peer) {
+			return false
+		}
+	}
+	return true
+}
+
+// OrFilter accept peers accepted by any of the given filters
+type OrFilter struct {
+	Filters []fab.TargetFilter
+}
+
+// Accept ..
+func (filter OrFilter) Accept(peer fab.Peer) bool {
+	for _, f := range filter.Filters {
+		if f.Accept(peer) {
+			return true
+		}
+	}
+	return false
+}
+
+// NotFilter accept peers rejected by the given filter
+type NotFilter struct {
+	Filter fab.TargetFilter
+}
+
+// Accept ..
+func (filter NotFilter) Accept(peer fab.Peer) bool {
+	return !filter.Filter.Accept(peer)
+}
@@ -0,0 +1,179 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package peerfilter
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/providers/fab"
+)
+
+// Spec declares a filter, eg. in configuration. A peer is accepted if it meets all the conditions set,
+// and every peer is accepted if none is set.
+type Spec struct {
+	MSP            []string          `mapstructure:"msp"`              // MSP IDs, any of them
+	URL            string            `mapstructure:"url"`              // regular expression matching the peer URL
+	Properties     map[string]string `mapstructure:"properties"`       // property values, all of them, see PeerProperties
+	MinBlockHeight uint64            `mapstructure:"min_block_height"` // minimum ledger height
+	Filter         string            `mapstructure:"filter"`           // name of another filter
+	And            []Spec            `mapstructure:"and"`
+	Or             []Spec            `mapstructure:"or"`
+	Not            *Spec             `mapstructure:"not"`
+}
+
+// PeerProperties declares the properties of a peer, eg. in configuration, which property filters select peers by
+type PeerProperties struct {
+	URL        string            `mapstructure:"url"`
+	Properties map[string]string `mapstructure:"properties"`
+}
+
+// NewNamedFilters builds the filters declared by specs, by name, property filters matching the given peer properties.
+// Filters can refer to one another by name, names of filters and properties being case insensitive.
+// A property no peer has is rejected, as its filters would never accept a peer.
+//
+func NewNamedFilters(specs map[string]Spec, peerProperties []PeerProperties) (map[string]fab.TargetFilter, error) {
+	b := &builder{
+		specs:      make(map[string]Spec),
+		filters:    make(map[string]fab.TargetFilter),
+		building:   make(map[string]bool),
+		properties: make(map[string]map[string]string),
+		declared:   make(map[string]bool),
+	}
+	for _, peer := range peerProperties {
+		if peer.URL == "" {
+			return nil, fmt.Errorf("peer properties need a url")
+		}
+		properties := make(map[string]string)
+		for name, value := range peer.Properties {
+			properties[strings.ToLower(name)] = value
+			b.declared[strings.ToLower(name)] = true
+		}
+		b.properties[peer.URL] = properties
+	}
+	var names []string
+	for name, spec := range specs {
+		name = strings.ToLower(name)
+		b.specs[name] = spec
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if _, err := b.named(name); err != nil {
+			return nil, err
+		}
+	}
+	return b.filters, nil
+}
+
+// builder builds named filters, resolving references once
+type builder struct {
+	specs    map[string]Spec
+	filters  map[string]fab.TargetFilter
+	building map[string]bool // filters being built, to detect cycles
+
+	properties map[string]map[string]string // properties of peers by URL, lower case names
+	declared   map[string]bool              // names of the properties of any peer
+}
+
+func (b *builder) named(name string) (fab.TargetFilter, error) {
+	name = strings.ToLower(name)
+	if filter, ok := b.filters[name]; ok {
+		return filter, nil
+	}
+	spec, ok := b.specs[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown peer filter: %s", name)
+	}
+	if b.building[name] {
+		return nil, fmt.Errorf("peer filter %s refers to itself", name)
+	}
+
+	b.building[name] = true
+	filter, err := b.build(spec)
+	delete(b.building, name)
+	if err != nil {
+		return nil, fmt.Errorf("peer filter %s: %s", name, err)
+	}
+	b.filters[name] = filter
+	return filter, nil
+}
+
+func (b *builder) build(spec Spec) (fab.TargetFilter, error) {
+	var filters []fab.TargetFilter
+	if len(spec.MSP) > 0 {
+		filters = append(filters, MSPSetFilter{MSPIDs: spec.MSP})
+	}
+	if spec.URL != "" {
+		re, err := regexp.Compile(spec.URL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid url regular expression: %s", err)
+		}
+		filters = append(filters, URLRegexpFilter{Regexp: re})
+	}
+	var properties []string
+	for name := range spec.Properties {
+		properties = append(properties, name)
+	}
+	sort.Strings(properties)
+	for _, name := range properties {
+		if !b.declared[strings.ToLower(name)] {
+			return nil, fmt.Errorf("property %s is not a property of any peer", name)
+		}
+		filters = append(filters, PropertyFilter{Name: name, Value: spec.Properties[name], Properties: b.properties})
+	}
+	if spec.MinBlockHeight > 0 {
+		filters = append(filters, MinBlockHeightFilter{MinHeight: spec.MinBlockHeight})
+	}
+	if spec.Filter != "" {
+		filter, err := b.named(spec.Filter)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, filter)
+	}
+	if len(spec.And) > 0 {
+		and, err := b.buildAll(spec.And)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, AndFilter{Filters: and})
+	}
+	if len(spec.Or) > 0 {
+		or, err := b.buildAll(spec.Or)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, OrFilter{Filters: or})
+	}
+	if spec.Not != nil {
+		not, err := b.build(*spec.Not)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, NotFilter{Filter: not})
+	}
+
+	if len(filters) == 1 {
+		return filters[0], nil
+	}
+	return AndFilter{Filters: filters}, nil
+}
+
+func (b *builder) buildAll(specs []Spec) ([]fab.TargetFilter, error) {
+	filters := make([]fab.TargetFilter, len(specs))
+	for i, spec := range specs {
+		filter, err := b.build(spec)
+		if err != nil {
+			return nil, err
+		}
+		filters[i] = filter
+	}
+	return filters, nil
+}
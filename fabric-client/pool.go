@@ -0,0 +1,202 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package fabricclient
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"github.com/hyperledger/fabric-sdk-go/pkg/client/channel"
+	"github.com/hyperledger/fabric-sdk-go/pkg/client/ledger"
+	fabapi "github.com/hyperledger/fabric-sdk-go/pkg/common/providers/fab"
+	sdkcfg "github.com/hyperledger/fabric-sdk-go/pkg/core/config"
+	"github.com/hyperledger/fabric-sdk-go/pkg/fab/comm"
+	"github.com/hyperledger/fabric-sdk-go/pkg/fabsdk"
+	"github.com/securekey/marbles-perf/fabric-client/factory"
+	"github.com/spf13/viper"
+)
+
+const (
+	configPoolSize       = configTreeNameFabricSDK + ".pool.size"
+	configPoolAssignment = configTreeNameFabricSDK + ".pool.assignment"
+
+	// PoolAssignRoundRobin hands the shards of the SDK pool to calls in turn
+	PoolAssignRoundRobin = "round_robin"
+	// PoolAssignWorkerHash hands calls made with the same WithShardKey to the same shard
+	PoolAssignWorkerHash = "worker_hash"
+)
+
+// sdkShard is one of the SDK instances of the pool, for invocations and for queries, along with their channel clients.
+// Each shard has its own gRPC connections, caches and locks.
+type sdkShard struct {
+	id             string
+	fabricSDK      *fabsdk.FabricSDK
+	fabricSDKQuery *fabsdk.FabricSDK
+
+	chclientMutex      sync.RWMutex
+	chClients          map[string]*channel.Client
+	chclientqueryMutex sync.RWMutex
+	chClientsQuery     map[string]*channel.Client
+	peersMutex         sync.RWMutex
+	peers              map[peerKey]fabapi.Peer
+	ledgerMutex        sync.RWMutex
+	ledgerClients      map[string]*ledger.Client
+}
+
+// peerKey identifies a peer calls are sent to with WithTargets or WithEndorsers
+type peerKey struct {
+	query     bool // created by the SDK instance for queries
+	channelID string
+	url       string
+}
+
+// sdkPool hands the shards to calls, round-robin or by the hash of their shard key
+type sdkPool struct {
+	shards     []*sdkShard
+	assignment string
+	next       uint64
+}
+
+// newSDKPool creates the SDK instances of the pool, fabric_sdk.pool.size of them sharing the given service provider factories
+func newSDKPool(sdkConfData []byte, invokeFactory *factory.MPerfServiceProviderFactory, queryFactory *factory.MPerfQueryServiceProviderFactory) (*sdkPool, error) {
+	size := viper.GetInt(configPoolSize)
+	if size <= 0 {
+		size = 1
+	}
+	pool := &sdkPool{assignment: viper.GetString(configPoolAssignment)}
+	switch pool.assignment {
+	case "":
+		pool.assignment = PoolAssignRoundRobin
+	case PoolAssignRoundRobin, PoolAssignWorkerHash:
+	default:
+		return nil, fmt.Errorf("configuration error, unknown %s: %s, available assignments are %s and %s", configPoolAssignment, pool.assignment, PoolAssignRoundRobin, PoolAssignWorkerHash)
+	}
+
+	for i := 0; i < size; i++ {
+		shard, err := newSDKShard(strconv.Itoa(i), sdkConfData, invokeFactory, queryFactory)
+		if err != nil {
+			pool.close()
+			return nil, err
+		}
+		pool.shards = append(pool.shards, shard)
+	}
+	if size > 1 {
+		logger.Infof("SDK pool of %d shards assigned by %s", size, pool.assignment)
+	}
+	return pool, nil
+}
+
+func newSDKShard(id string, sdkConfData []byte, invokeFactory *factory.MPerfServiceProviderFactory, queryFactory *factory.MPerfQueryServiceProviderFactory) (*sdkShard, error) {
+	shard := &sdkShard{
+		id:             id,
+		chClients:      make(map[string]*channel.Client),
+		chClientsQuery: make(map[string]*channel.Client),
+		peers:          make(map[peerKey]fabapi.Peer),
+		ledgerClients:  make(map[string]*ledger.Client),
+	}
+
+	var err error
+	apiConfig := sdkcfg.FromRaw(sdkConfData, "yaml")
+	shard.fabricSDK, err = fabsdk.New(
+		apiConfig,
+		fabsdk.WithServicePkg(invokeFactory))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create new SDK: %v", err)
+	}
+
+	apiConfigQuery := sdkcfg.FromRaw(sdkConfData, "yaml")
+	shard.fabricSDKQuery, err = fabsdk.New(
+		apiConfigQuery,
+		//fabsdk.WithCorePkg(factory.NewMPerfCoreProviderFactory()),
+		fabsdk.WithServicePkg(queryFactory))
+	if err != nil {
+		shard.fabricSDK.Close()
+		return nil, fmt.Errorf("failed to create new SDK for chaincode query: %v", err)
+	}
+	return shard, nil
+}
+
+// peer returns the peer of a URL as in the SDK configuration, created once per channel by the SDK instance for invocations or queries
+func (s *sdkShard) peer(query bool, channelID string, orgName string, userID string, peerURL string) (fabapi.Peer, error) {
+	key := peerKey{query: query, channelID: channelID, url: peerURL}
+	s.peersMutex.RLock()
+	peer, exists := s.peers[key]
+	s.peersMutex.RUnlock()
+	if exists {
+		return peer, nil
+	}
+
+	fabricSDK := s.fabricSDK
+	if query {
+		fabricSDK = s.fabricSDKQuery
+	}
+	chContext, err := fabricSDK.ChannelContext(channelID, fabsdk.WithUser(userID), fabsdk.WithOrg(orgName))()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create context for channel %s: %s", channelID, err)
+	}
+	peerCfg, err := comm.NetworkPeerConfig(chContext.EndpointConfig(), peerURL)
+	if err != nil {
+		return nil, fmt.Errorf("peer %s not found in the SDK configuration: %s", peerURL, err)
+	}
+	peer, err = chContext.InfraProvider().CreatePeerFromConfig(peerCfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create peer %s: %s", peerURL, err)
+	}
+
+	s.peersMutex.Lock()
+	s.peers[key] = peer
+	s.peersMutex.Unlock()
+	return peer, nil
+}
+
+// ledgerClient returns the ledger client of a channel for a user of an organization, created once
+func (s *sdkShard) ledgerClient(channelID string, orgName string, userID string) (*ledger.Client, error) {
+	key := channelClientKey(channelID, orgName, userID)
+	s.ledgerMutex.RLock()
+	ledgerClient, exists := s.ledgerClients[key]
+	s.ledgerMutex.RUnlock()
+	if exists {
+		return ledgerClient, nil
+	}
+
+	ledgerClient, err := ledger.New(s.fabricSDK.ChannelContext(channelID, fabsdk.WithUser(userID), fabsdk.WithOrg(orgName)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create ledger client for channel %s: %s", channelID, err)
+	}
+	s.ledgerMutex.Lock()
+	s.ledgerClients[key] = ledgerClient
+	s.ledgerMutex.Unlock()
+	return ledgerClient, nil
+}
+
+// shard returns the shard of a call, by the hash of its shard key if shards are assigned by worker hash and the call has one
+func (p *sdkPool) shard(key string) *sdkShard {
+	if len(p.shards) == 1 {
+		return p.shards[0]
+	}
+	if p.assignment == PoolAssignWorkerHash && key != "" {
+		h := fnv.New32a()
+		h.Write([]byte(key))
+		return p.shards[h.Sum32()%uint32(len(p.shards))]
+	}
+	return p.shards[(atomic.AddUint64(&p.next, 1)-1)%uint64(len(p.shards))]
+}
+
+func (p *sdkPool) close() {
+	for _, shard := range p.shards {
+		shard.fabricSDK.Close()
+		shard.fabricSDKQuery.Close()
+	}
+}
+
+// PoolSize returns the number of SDK instances calls are spread over
+//
+func (t *fabClient) PoolSize() int {
+	return len(t.pool.shards)
+}
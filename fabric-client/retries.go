@@ -0,0 +1,49 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package fabricclient
+
+import (
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/errors/status"
+)
+
+// Retry is a retried attempt of a chaincode invocation or query, along with the status that triggered it
+type Retry struct {
+	Group   string // status group, eg. "Chaincode status"
+	Code    int32  // status code within the group
+	Message string
+	Peers   []string // addresses of the endorsing peers the retried error is attributed to
+}
+
+func newRetry(err error) Retry {
+	s, ok := status.FromError(err)
+	if !ok {
+		return Retry{Group: status.UnknownStatus.String(), Message: err.Error(), Peers: ErrorPeers(err)}
+	}
+	return Retry{Group: s.Group.String(), Code: s.Code, Message: s.Message, Peers: ErrorPeers(err)}
+}
+
+// ErrorRetries returns the retries of a chaincode call that failed with err
+//
+func ErrorRetries(err error) []Retry {
+	if e, ok := AsError(err); ok {
+		return e.Retries
+	}
+	return nil
+}
+
+// attachRetries attaches the retries of a chaincode call to its response, or to its error if it failed
+func attachRetries(retries []Retry, ccResp *CCResponse, err error) (*CCResponse, error) {
+	if err != nil {
+		if e, ok := err.(*Error); ok {
+			e.Retries = retries
+		}
+		return ccResp, err
+	}
+	if ccResp != nil {
+		ccResp.Retries = retries
+	}
+	return ccResp, err
+}
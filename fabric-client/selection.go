@@ -0,0 +1,96 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package fabricclient
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/securekey/marbles-perf/fabric-client/factory"
+	"github.com/spf13/viper"
+)
+
+const (
+	configSelectionInvokeStrategy = configTreeNameFabricSDK + ".selection.invoke.strategy"
+	configSelectionQueryStrategy  = configTreeNameFabricSDK + ".selection.query.strategy"
+	configSelectionWeights        = configTreeNameFabricSDK + ".selection.weights"
+
+	configCircuitBreakerEnabled           = configTreeNameFabricSDK + ".selection.circuit_breaker.enabled"
+	configCircuitBreakerFailureThreshold  = configTreeNameFabricSDK + ".selection.circuit_breaker.failure_threshold"
+	configCircuitBreakerBackoffSeconds    = configTreeNameFabricSDK + ".selection.circuit_breaker.backoff_seconds"
+	configCircuitBreakerMaxBackoffSeconds = configTreeNameFabricSDK + ".selection.circuit_breaker.max_backoff_seconds"
+)
+
+// SelectionStrategies are the strategies selecting the endorsing peers of invocations and the peer of queries
+type SelectionStrategies struct {
+	Invoke string
+	Query  string
+}
+
+// peerWeight is the weight of a peer configured under fabric_sdk.selection.weights.
+// Peers are listed rather than mapped as viper splits keys on dots.
+type peerWeight struct {
+	Peer   string `mapstructure:"peer"`
+	Weight int    `mapstructure:"weight"`
+}
+
+// selectionOptions returns the options of the invoke and query service provider factories
+func selectionOptions() (invoke factory.SelectionOptions, query factory.SelectionOptions, err error) {
+	var weights []peerWeight
+	if err := viper.UnmarshalKey(configSelectionWeights, &weights); err != nil {
+		return invoke, query, fmt.Errorf("configuration error, invalid %s: %s", configSelectionWeights, err)
+	}
+	peerWeights := make(map[string]int)
+	for _, w := range weights {
+		if w.Peer == "" {
+			return invoke, query, fmt.Errorf("configuration error, weights under %s need a peer", configSelectionWeights)
+		}
+		peerWeights[w.Peer] = w.Weight
+	}
+
+	invoke = factory.SelectionOptions{Strategy: viper.GetString(configSelectionInvokeStrategy), Weights: peerWeights}
+	if invoke.Strategy == "" {
+		invoke.Strategy = factory.StrategyRandom
+	}
+	query = factory.SelectionOptions{Strategy: viper.GetString(configSelectionQueryStrategy), Weights: peerWeights}
+	if query.Strategy == "" {
+		query.Strategy = factory.StrategyRandom
+	}
+	return invoke, query, nil
+}
+
+// circuitBreakerOptions returns how peers that keep failing are taken out of selection, enabled unless configured otherwise
+func circuitBreakerOptions() (factory.CircuitBreakerOptions, error) {
+	opts := factory.DefaultCircuitBreakerOptions
+	if viper.IsSet(configCircuitBreakerEnabled) {
+		opts.Enabled = viper.GetBool(configCircuitBreakerEnabled)
+	}
+	if threshold := viper.GetInt(configCircuitBreakerFailureThreshold); threshold > 0 {
+		opts.FailureThreshold = threshold
+	}
+	if seconds := viper.GetInt(configCircuitBreakerBackoffSeconds); seconds > 0 {
+		opts.Backoff = time.Duration(seconds) * time.Second
+	}
+	if seconds := viper.GetInt(configCircuitBreakerMaxBackoffSeconds); seconds > 0 {
+		opts.MaxBackoff = time.Duration(seconds) * time.Second
+	}
+	if opts.MaxBackoff < opts.Backoff {
+		return opts, fmt.Errorf("configuration error, %s must not be lower than %s", configCircuitBreakerMaxBackoffSeconds, configCircuitBreakerBackoffSeconds)
+	}
+	return opts, nil
+}
+
+// PeerHealth returns the health of the peers selected so far, as tracked by selection
+//
+func (t *fabClient) PeerHealth() []factory.PeerHealth {
+	return factory.PeerHealthStates()
+}
+
+// SelectionStrategies returns the strategies selecting the endorsing peers of invocations and the peer of queries
+//
+func (t *fabClient) SelectionStrategies() SelectionStrategies {
+	return t.selection
+}
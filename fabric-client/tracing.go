@@ -0,0 +1,42 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package fabricclient
+
+import (
+	"context"
+
+	"github.com/securekey/marbles-perf/utils"
+)
+
+// startCCSpan starts the client span of a chaincode call, child of the span of ctx if any
+func startCCSpan(ctx context.Context, name string, channelID string, chainCodeID string, args []string) (context.Context, *utils.Span) {
+	var function string
+	if len(args) > 0 {
+		function = args[0]
+	}
+	return utils.StartSpan(ctx, name,
+		utils.WithSpanKind(utils.SpanKindClient),
+		utils.WithAttributes(
+			"fabric.channel", channelID,
+			"fabric.chaincode", chainCodeID,
+			"fabric.function", function,
+		))
+}
+
+// endCCSpan ends the span of a chaincode call with the fabric transaction details
+func endCCSpan(span *utils.Span, resp *CCResponse, err error) {
+	if resp != nil {
+		span.SetAttributes(
+			"fabric.tx_id", resp.FabricTxnID,
+			"fabric.endorsers", resp.Endorsers,
+			"fabric.retries", len(resp.Retries),
+		)
+	} else if err != nil {
+		span.SetAttributes("fabric.retries", len(ErrorRetries(err)))
+	}
+	span.SetError(err)
+	span.End()
+}
@@ -0,0 +1,120 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+
+	"github.com/securekey/marbles-perf/api"
+	fabricclient "github.com/securekey/marbles-perf/fabric-client"
+)
+
+// how the identities or organizations configured in the fabric client are assigned to the workers of a batch run
+const (
+	assignRoundRobin = "round_robin"
+	assignRandom     = "random"
+)
+
+// validateAssignment checks how the workers of a batch run are to be assigned identities or organizations
+//
+func validateAssignment(kind string, assignment string) error {
+	switch assignment {
+	case "", assignRoundRobin, assignRandom:
+		return nil
+	default:
+		return fmt.Errorf("unknown %s assignment: %s, available assignments are %s and %s", kind, assignment, assignRoundRobin, assignRandom)
+	}
+}
+
+// validateAssignments checks the identity and organization assignments of a batch request, the endorsers and orderers it pins
+// and the peer filter it names
+//
+func validateAssignments(req *api.InitBatchRequest) error {
+	if err := validateAssignment("identity", req.Identities); err != nil {
+		return err
+	}
+	if err := validateAssignment("organization", req.Organizations); err != nil {
+		return err
+	}
+	for _, peers := range req.Endorsers {
+		if len(peers) == 0 {
+			return fmt.Errorf("endorsers must not include an empty set of peers")
+		}
+		for _, peer := range peers {
+			if peer == "" {
+				return fmt.Errorf("endorsers must not include an empty peer URL")
+			}
+		}
+	}
+	for _, orderer := range req.Orderers {
+		if orderer == "" {
+			return fmt.Errorf("orderers must not include an empty orderer")
+		}
+	}
+	if req.PeerFilter != "" {
+		if _, err := fc.PeerFilter(req.PeerFilter); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// assign returns the value assigned to a worker, empty if there is no assignment
+//
+func assign(assignment string, workerID int, values []string) string {
+	switch assignment {
+	case assignRoundRobin:
+		return values[(workerID-1)%len(values)]
+	case assignRandom:
+		return values[rand.Intn(len(values))]
+	default:
+		return ""
+	}
+}
+
+// assignWorker assigns the organization and the identity a worker transacts as, and the endorsers and orderer it is pinned to.
+// Identities are members of the default organization, so workers of other organizations transact as the user of their organization.
+// Endorser sets and orderers are assigned round-robin.
+//
+func (tg *TransfersGenerator) assignWorker(w *MarbleWorker) {
+	orgs := fc.Orgs()
+	w.org = assign(tg.request.Organizations, w.id, orgs)
+	if w.org == "" || w.org == orgs[0] {
+		w.identity = assign(tg.request.Identities, w.id, fc.Identities())
+	}
+	if len(tg.request.Endorsers) > 0 {
+		w.endorsers = tg.request.Endorsers[(w.id-1)%len(tg.request.Endorsers)]
+	}
+	if len(tg.request.Orderers) > 0 {
+		w.orderer = assign(assignRoundRobin, w.id, tg.request.Orderers)
+	}
+	w.peerFilter = tg.request.PeerFilter
+}
+
+// callOptions returns the options of the chaincode calls of a worker
+//
+func (w *MarbleWorker) callOptions() []fabricclient.CallOption {
+	// a worker sticks to one SDK instance of the pool if the pool assigns them by worker hash
+	opts := []fabricclient.CallOption{fabricclient.WithShardKey(strconv.Itoa(w.id))}
+	if w.org != "" {
+		opts = append(opts, fabricclient.WithOrg(w.org))
+	}
+	if w.identity != "" {
+		opts = append(opts, fabricclient.WithIdentity(w.identity))
+	}
+	if len(w.endorsers) > 0 {
+		opts = append(opts, fabricclient.WithEndorsers(w.endorsers...))
+	}
+	if w.orderer != "" {
+		opts = append(opts, fabricclient.WithOrderer(w.orderer))
+	}
+	if w.peerFilter != "" {
+		opts = append(opts, fabricclient.WithNamedPeerFilter(w.peerFilter))
+	}
+	return opts
+}
@@ -0,0 +1,145 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/spf13/viper"
+)
+
+const configAuthorizationRules = "http.server.authorization.rules"
+
+// authorizationRule restricts the requests to a route to the clients whose certificate has one of the subjects
+type authorizationRule struct {
+	Path     string   `mapstructure:"path"`    // route template, eg. /marble/{id}
+	Methods  []string `mapstructure:"methods"` // HTTP methods, any method if empty
+	Subjects []string `mapstructure:"subjects"`
+}
+
+func (rule authorizationRule) matches(route, method string) bool {
+	if rule.Path != route {
+		return false
+	}
+	if len(rule.Methods) == 0 {
+		return true
+	}
+	for _, m := range rule.Methods {
+		if strings.EqualFold(m, method) {
+			return true
+		}
+	}
+	return false
+}
+
+// allows tells whether a client certificate subject, as a distinguished name or a common name, is one of the subjects of the rule
+func (rule authorizationRule) allows(subject, commonName string) bool {
+	for _, s := range rule.Subjects {
+		if s == subject || (commonName != "" && s == commonName) {
+			return true
+		}
+	}
+	return false
+}
+
+// authorizer restricts routes to client certificate subjects, routes no rule matches are open to all clients
+type authorizer struct {
+	rules []authorizationRule
+}
+
+// newAuthorizer loads the authorization rules, which need clients to be authenticated by certificate
+//
+func newAuthorizer() (*authorizer, error) {
+	var rules []authorizationRule
+	if err := viper.UnmarshalKey(configAuthorizationRules, &rules); err != nil {
+		return nil, fmt.Errorf("configuration error, invalid %s: %s", configAuthorizationRules, err)
+	}
+	for _, rule := range rules {
+		if rule.Path == "" {
+			return nil, fmt.Errorf("configuration error, rules under %s need a path", configAuthorizationRules)
+		}
+	}
+	if len(rules) > 0 && !mutualTLSEnabled() {
+		return nil, fmt.Errorf("configuration error, %s need TLS to be enabled with %s", configAuthorizationRules, configTLSClientCAFile)
+	}
+	return &authorizer{rules: rules}, nil
+}
+
+// checkRoutes rejects the rules that match no route of the router, eg. because of a typo in their path,
+// as they would leave open the routes they were meant to restrict
+//
+func (a *authorizer) checkRoutes(router *mux.Router) error {
+	matched := make([]bool, len(a.rules))
+	err := router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		template, err := route.GetPathTemplate()
+		if err != nil {
+			return nil
+		}
+		// a route without methods serves any method
+		methods, _ := route.GetMethods()
+		for i, rule := range a.rules {
+			if rule.Path != template {
+				continue
+			}
+			if len(rule.Methods) == 0 || len(methods) == 0 {
+				matched[i] = true
+				continue
+			}
+			for _, method := range methods {
+				if rule.matches(template, method) {
+					matched[i] = true
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to walk the routes: %s", err)
+	}
+
+	for i, rule := range a.rules {
+		if !matched[i] {
+			return fmt.Errorf("configuration error, rule of %s for path %s and methods %v matches no route", configAuthorizationRules, rule.Path, rule.Methods)
+		}
+	}
+	return nil
+}
+
+// authorize is a mux middleware rejecting the requests the authorization rules do not allow, by their verified client certificate
+//
+func (a *authorizer) authorize(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := routeTemplate(r)
+		var matched []authorizationRule
+		for _, rule := range a.rules {
+			if rule.matches(route, r.Method) {
+				matched = append(matched, rule)
+			}
+		}
+		if len(matched) == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+			writeErrorResponse(w, http.StatusForbidden, fmt.Sprintf("%s %s requires a client certificate", r.Method, route))
+			return
+		}
+		cert := r.TLS.VerifiedChains[0][0]
+		subject := cert.Subject.String()
+		for _, rule := range matched {
+			if rule.allows(subject, cert.Subject.CommonName) {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+		logger.Warningf("%s %s denied to client %s", r.Method, route, subject)
+		writeErrorResponse(w, http.StatusForbidden, fmt.Sprintf("%s %s not allowed for client %s", r.Method, route, subject))
+	})
+}
@@ -0,0 +1,288 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/securekey/marbles-perf/api"
+	"github.com/spf13/viper"
+)
+
+const (
+	ledgerKeyBaselinePrefix = "BSL_"
+
+	verdictOK        = "ok"
+	verdictRegressed = "regressed"
+
+	configBaselineTolerances          = "batch.baseline.tolerances"
+	configToleranceThroughputPercent  = configBaselineTolerances + ".throughput_percent"
+	configToleranceAvgLatencyPercent  = configBaselineTolerances + ".average_latency_percent"
+	configTolerancePctLatencyPercent  = configBaselineTolerances + ".percentile_latency_percent"
+	configToleranceErrorRate          = configBaselineTolerances + ".error_rate"
+	defaultToleranceThroughputPercent = 10
+	defaultToleranceAvgLatencyPercent = 10
+	defaultTolerancePctLatencyPercent = 15
+	defaultToleranceErrorRate         = 0.01
+)
+
+// setBaseline marks an existing batch run as the baseline of a scenario
+//
+func setBaseline(w http.ResponseWriter, r *http.Request) {
+	scenario := mux.Vars(r)["scenario"]
+	if scenario == "" {
+		writeErrorResponse(w, http.StatusBadRequest, "scenario not provided")
+		return
+	}
+
+	payload, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, "failed to read request body: %s", err)
+		return
+	}
+
+	var req api.SetBaselineRequest
+	if err := json.Unmarshal(payload, &req); err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, "failed to parse payload json: %s", err)
+		return
+	}
+	if req.BatchID == "" {
+		writeErrorResponse(w, http.StatusBadRequest, "batchId not provided")
+		return
+	}
+
+	result, err := readBatchResults(req.BatchID)
+	if err != nil {
+		writeErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if result == nil {
+		writeErrorResponse(w, http.StatusNotFound, "Batch run status not yet available (not complete)")
+		return
+	}
+
+	baseline := api.Baseline{
+		Scenario: scenario,
+		BatchID:  req.BatchID,
+		Result:   *result,
+	}
+	if err := storeBaseline(baseline); err != nil {
+		writeErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSONResponse(w, http.StatusOK, baseline)
+}
+
+// getBaseline retrieves the baseline of a scenario
+//
+func getBaseline(w http.ResponseWriter, r *http.Request) {
+	scenario := mux.Vars(r)["scenario"]
+	if scenario == "" {
+		writeErrorResponse(w, http.StatusBadRequest, "scenario not provided")
+		return
+	}
+
+	baseline, err := readBaseline(scenario)
+	if err != nil {
+		writeErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if baseline == nil {
+		writeErrorResponse(w, http.StatusNotFound, "no baseline for scenario %s", scenario)
+		return
+	}
+	writeJSONResponse(w, http.StatusOK, baseline)
+}
+
+// fetchBatchComparison returns the comparison of a batch run with the baseline of its scenario.
+// The comparison made at the end of the run is returned if there is one, else the run is compared
+// with the current baseline.
+//
+func fetchBatchComparison(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	if id == "" {
+		writeErrorResponse(w, http.StatusBadRequest, "missing batch ids")
+		return
+	}
+
+	result, err := readBatchResults(id)
+	if err != nil {
+		writeErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if result == nil {
+		writeErrorResponse(w, http.StatusNotFound, "Batch run status not yet available (not complete)")
+		return
+	}
+	if result.Comparison != nil {
+		writeJSONResponse(w, http.StatusOK, result.Comparison)
+		return
+	}
+	if result.Request.Scenario == "" {
+		writeErrorResponse(w, http.StatusNotFound, "batch run %s is not part of a scenario", id)
+		return
+	}
+
+	baseline, err := readBaseline(result.Request.Scenario)
+	if err != nil {
+		writeErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if baseline == nil {
+		writeErrorResponse(w, http.StatusNotFound, "no baseline for scenario %s", result.Request.Scenario)
+		return
+	}
+
+	comparison := compareWithBaseline(*result, *baseline, effectiveTolerances(result.Request))
+	writeJSONResponse(w, http.StatusOK, comparison)
+}
+
+// readBatchResults reads the results of a batch run from the ledger, nil is returned if the run is not complete
+//
+func readBatchResults(id string) (*api.BatchResult, error) {
+	data, err := readLedgerValue(id + ledgerKeyBatchResults)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch batch run status from ledger: %s", err)
+	}
+	if len(data) == 0 {
+		return nil, nil
+	}
+
+	var result api.BatchResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("failed to JSON unmarshal batch run results: %s", err)
+	}
+	if result.BatchID == "" {
+		result.BatchID = id
+	}
+	return &result, nil
+}
+
+// storeBaseline writes the baseline of a scenario to the ledger
+//
+func storeBaseline(baseline api.Baseline) error {
+	baselineJSON, err := json.Marshal(baseline)
+	if err != nil {
+		return fmt.Errorf("failed to JSON marshal baseline: %s", err)
+	}
+	logger.Infof("setting batch run %s as baseline of scenario %s", baseline.BatchID, baseline.Scenario)
+	return writeLedgerValue(ledgerKeyBaselinePrefix+baseline.Scenario, string(baselineJSON))
+}
+
+// readBaseline reads the baseline of a scenario from the ledger, nil is returned if there is none
+//
+func readBaseline(scenario string) (*api.Baseline, error) {
+	data, err := readLedgerValue(ledgerKeyBaselinePrefix + scenario)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, nil
+	}
+
+	var baseline api.Baseline
+	if err := json.Unmarshal(data, &baseline); err != nil {
+		return nil, fmt.Errorf("failed to JSON unmarshal baseline of scenario %s: %s", scenario, err)
+	}
+	return &baseline, nil
+}
+
+// effectiveTolerances returns the tolerances of the batch request if set, else the configured or default tolerances
+//
+func effectiveTolerances(req api.InitBatchRequest) api.Tolerances {
+	if req.Tolerances != nil {
+		return *req.Tolerances
+	}
+
+	tolerances := api.Tolerances{
+		ThroughputPercent:        defaultToleranceThroughputPercent,
+		AverageLatencyPercent:    defaultToleranceAvgLatencyPercent,
+		PercentileLatencyPercent: defaultTolerancePctLatencyPercent,
+		ErrorRate:                defaultToleranceErrorRate,
+	}
+	if viper.IsSet(configToleranceThroughputPercent) {
+		tolerances.ThroughputPercent = viper.GetFloat64(configToleranceThroughputPercent)
+	}
+	if viper.IsSet(configToleranceAvgLatencyPercent) {
+		tolerances.AverageLatencyPercent = viper.GetFloat64(configToleranceAvgLatencyPercent)
+	}
+	if viper.IsSet(configTolerancePctLatencyPercent) {
+		tolerances.PercentileLatencyPercent = viper.GetFloat64(configTolerancePctLatencyPercent)
+	}
+	if viper.IsSet(configToleranceErrorRate) {
+		tolerances.ErrorRate = viper.GetFloat64(configToleranceErrorRate)
+	}
+	return tolerances
+}
+
+// compareWithBaseline compares the results of a batch run with the baseline results.
+// The run is regressed if any of the metrics deviates from the baseline more than tolerated.
+//
+func compareWithBaseline(result api.BatchResult, baseline api.Baseline, tolerances api.Tolerances) api.BaselineComparison {
+	base := baseline.Result
+	deltas := []api.MetricDelta{
+		lowerIsWorse("throughput", base.Throughput, result.Throughput, tolerances.ThroughputPercent),
+		higherIsWorse("averageTransferSeconds", base.AverageTransferSeconds, result.AverageTransferSeconds, tolerances.AverageLatencyPercent),
+		higherIsWorse("p50TransferSeconds", base.P50TransferSeconds, result.P50TransferSeconds, tolerances.PercentileLatencyPercent),
+		higherIsWorse("p90TransferSeconds", base.P90TransferSeconds, result.P90TransferSeconds, tolerances.PercentileLatencyPercent),
+		higherIsWorse("p99TransferSeconds", base.P99TransferSeconds, result.P99TransferSeconds, tolerances.PercentileLatencyPercent),
+	}
+
+	// error rate is already a ratio so its tolerance is an absolute increase rather than a percentage
+	errorRate := newMetricDelta("errorRate", base.ErrorRate, result.ErrorRate, tolerances.ErrorRate)
+	errorRate.Regressed = errorRate.Delta > tolerances.ErrorRate
+	deltas = append(deltas, errorRate)
+
+	verdict := verdictOK
+	for _, delta := range deltas {
+		if delta.Regressed {
+			verdict = verdictRegressed
+			break
+		}
+	}
+
+	return api.BaselineComparison{
+		Scenario:        baseline.Scenario,
+		BaselineBatchID: baseline.BatchID,
+		Verdict:         verdict,
+		Tolerances:      tolerances,
+		Deltas:          deltas,
+	}
+}
+
+func newMetricDelta(metric string, baseline, current, tolerance float64) api.MetricDelta {
+	delta := api.MetricDelta{
+		Metric:    metric,
+		Baseline:  baseline,
+		Current:   current,
+		Delta:     roundMillis(current - baseline),
+		Tolerance: tolerance,
+	}
+	if baseline != 0 {
+		delta.DeltaPercent = roundMillis((current - baseline) / baseline * 100)
+	}
+	return delta
+}
+
+// lowerIsWorse compares a metric that regresses when it decreases, such as throughput
+//
+func lowerIsWorse(metric string, baseline, current, tolerancePercent float64) api.MetricDelta {
+	delta := newMetricDelta(metric, baseline, current, tolerancePercent)
+	delta.Regressed = baseline > 0 && -delta.DeltaPercent > tolerancePercent
+	return delta
+}
+
+// higherIsWorse compares a metric that regresses when it increases, such as latency
+//
+func higherIsWorse(metric string, baseline, current, tolerancePercent float64) api.MetricDelta {
+	delta := newMetricDelta(metric, baseline, current, tolerancePercent)
+	delta.Regressed = baseline > 0 && delta.DeltaPercent > tolerancePercent
+	return delta
+}
@@ -6,15 +6,24 @@ SPDX-License-Identifier: Apache-2.0
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"io/ioutil"
 	"net/http"
+	"sync"
 
 	"github.com/gorilla/mux"
 	"github.com/securekey/marbles-perf/api"
 	"github.com/securekey/marbles-perf/utils"
 )
 
+var (
+	runningBatchesLock sync.Mutex
+	// cancel functions of the batch runs in progress, by batch id
+	runningBatches = map[string]context.CancelFunc{}
+)
+
 func initBatchTransfers(w http.ResponseWriter, r *http.Request) {
 	reqBody, err := ioutil.ReadAll(r.Body)
 	if err != nil {
@@ -27,13 +36,16 @@ func initBatchTransfers(w http.ResponseWriter, r *http.Request) {
 		writeErrorResponse(w, http.StatusInternalServerError, "failed to json unmarshal request content: %s", err)
 		return
 	}
+	if err := validateAssignments(&batchRequest); err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
 
-	id, err := utils.GenerateRandomAlphaNumericString(24)
+	id, err := newBatchRunID()
 	if err != nil {
-		writeErrorResponse(w, http.StatusInternalServerError, "failed to generate random batch run id: %s", err)
+		writeErrorResponse(w, http.StatusInternalServerError, err.Error())
 		return
 	}
-	id = "b" + id
 	resp := api.InitBatchResponse{
 		BatchID: id,
 	}
@@ -50,7 +62,7 @@ func fetchBatchResults(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	statusResp, err := fc.QueryCC(1, ConsortiumChannelID, MarblesCC, []string{"read", id + ledgerKeyBatchResults}, nil)
+	statusResp, err := fc.QueryCCContext(r.Context(), ConsortiumChannelID, MarblesCC, []string{"read", id + ledgerKeyBatchResults}, nil)
 	if err != nil {
 		writeErrorResponse(w, http.StatusInternalServerError, "failed to fetch batch run status from ledger: %s", err)
 		return
@@ -66,7 +78,49 @@ func fetchBatchResults(w http.ResponseWriter, r *http.Request) {
 	w.Write(statusResp.Payload)
 }
 
-func doBatchTransfers(id string, batchReq api.InitBatchRequest) {
+// cancelBatchRun cancels a batch run in progress. Its workers stop once their fabric calls under way are abandoned,
+// and the results of the transfers completed so far are stored with status cancelled.
+//
+func cancelBatchRun(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	runningBatchesLock.Lock()
+	cancel, running := runningBatches[id]
+	runningBatchesLock.Unlock()
+	if !running {
+		writeErrorResponse(w, http.StatusNotFound, "batch run %s not in progress", id)
+		return
+	}
+
+	cancel()
+	logger.Infof("batch run %s cancelled", id)
+	writeJSONResponse(w, http.StatusAccepted, api.InitBatchResponse{BatchID: id})
+}
+
+// doBatchTransfers runs a batch of transfers to completion, or until cancelled, and returns its results
+//
+func doBatchTransfers(id string, batchReq api.InitBatchRequest) api.BatchResult {
+	ctx, cancel := context.WithCancel(context.Background())
+	runningBatchesLock.Lock()
+	runningBatches[id] = cancel
+	runningBatchesLock.Unlock()
+	defer func() {
+		runningBatchesLock.Lock()
+		delete(runningBatches, id)
+		runningBatchesLock.Unlock()
+		cancel()
+	}()
+
 	tg := NewTransfersGenerator(id, batchReq)
-	tg.run()
+	return tg.run(ctx)
+}
+
+// newBatchRunID generates a random batch run id
+//
+func newBatchRunID() (string, error) {
+	id, err := utils.GenerateRandomAlphaNumericString(24)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate random batch run id: %s", err)
+	}
+	return "b" + id, nil
 }
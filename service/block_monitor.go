@@ -0,0 +1,119 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"time"
+
+	"github.com/securekey/marbles-perf/api"
+	fabricclient "github.com/securekey/marbles-perf/fabric-client"
+	"github.com/spf13/viper"
+)
+
+const (
+	configBlocksMonitor           = "batch.blocks.monitor"
+	configBlocksMaxMessageCount   = "batch.blocks.max_message_count"
+	configBlocksPreferredMaxBytes = "batch.blocks.preferred_max_bytes"
+
+	// fabric's default orderer batch size
+	defaultBlocksMaxMessageCount   = 10
+	defaultBlocksPreferredMaxBytes = 512 * 1024
+
+	validationCodeValid = "VALID"
+)
+
+// blockMonitor collects the blocks committed on the consortium channel during a batch run
+type blockMonitor struct {
+	stop   func()
+	done   chan struct{}
+	blocks []*fabricclient.BlockInfo
+}
+
+// blockMonitorEnabled tells whether batch runs monitor blocks, which they do unless disabled in configuration
+//
+func blockMonitorEnabled() bool {
+	return !viper.IsSet(configBlocksMonitor) || viper.GetBool(configBlocksMonitor)
+}
+
+func startBlockMonitor() (*blockMonitor, error) {
+	blocks, stop, err := fc.ListenBlocks(ConsortiumChannelID)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &blockMonitor{stop: stop, done: make(chan struct{})}
+	go func() {
+		defer close(m.done)
+		for block := range blocks {
+			m.blocks = append(m.blocks, block)
+		}
+	}()
+	return m, nil
+}
+
+// summary stops the monitor and summarizes the blocks it collected.
+// Orderers cut a block once it reaches the max message count or the preferred max bytes of their batch size,
+// or when the batch timeout expires. Blocks are assumed to be cut by timeout unless they reach either size limit.
+//
+func (m *blockMonitor) summary() *api.BlockSummary {
+	m.stop()
+	<-m.done
+
+	maxMessageCount := viper.GetInt(configBlocksMaxMessageCount)
+	if maxMessageCount <= 0 {
+		maxMessageCount = defaultBlocksMaxMessageCount
+	}
+	preferredMaxBytes := viper.GetInt(configBlocksPreferredMaxBytes)
+	if preferredMaxBytes <= 0 {
+		preferredMaxBytes = defaultBlocksPreferredMaxBytes
+	}
+
+	summary := &api.BlockSummary{
+		Blocks:          len(m.blocks),
+		ValidationCodes: make(map[string]int),
+	}
+	if len(m.blocks) == 0 {
+		return summary
+	}
+
+	var totalBytes int
+	var totalInterval, maxInterval time.Duration
+	for i, block := range m.blocks {
+		summary.Transactions += block.Transactions
+		totalBytes += block.Size
+		if block.Size > summary.MaxBlockBytes {
+			summary.MaxBlockBytes = block.Size
+		}
+		for code, count := range block.ValidationCodes {
+			summary.ValidationCodes[code] += count
+			if code != validationCodeValid {
+				summary.InvalidTransactions += count
+			}
+		}
+
+		if block.Transactions >= maxMessageCount || block.Size >= preferredMaxBytes {
+			summary.CutBySize++
+		} else {
+			summary.CutByTimeout++
+		}
+
+		if i > 0 {
+			interval := block.ReceivedAt.Sub(m.blocks[i-1].ReceivedAt)
+			totalInterval += interval
+			if interval > maxInterval {
+				maxInterval = interval
+			}
+		}
+	}
+
+	summary.AverageTransactionsPerBlock = roundMillis(float64(summary.Transactions) / float64(len(m.blocks)))
+	summary.AverageBlockBytes = totalBytes / len(m.blocks)
+	if len(m.blocks) > 1 {
+		summary.AverageBlockIntervalSeconds = roundSeconds(totalInterval / time.Duration(len(m.blocks)-1))
+	}
+	summary.MaxBlockIntervalSeconds = roundSeconds(maxInterval)
+	return summary
+}
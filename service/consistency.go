@@ -0,0 +1,316 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/securekey/marbles-perf/api"
+	fabricclient "github.com/securekey/marbles-perf/fabric-client"
+	"github.com/securekey/marbles-perf/utils"
+)
+
+// statusLedgerDiverged is the status of a batch run after which the ledgers of the peers were found to differ
+const statusLedgerDiverged = "ledger_diverged"
+
+// keyObservations are the values of keys read at the peers
+type keyObservations struct {
+	lock     sync.Mutex
+	values   map[string]map[string]string // value by peer, by key; a peer that answered without the key has none
+	answered map[string]map[string]bool   // peers that answered, by key
+	allPeers []string                     // peers that answered with all keys, see observeAll
+	errors   []string
+}
+
+func newKeyObservations() *keyObservations {
+	return &keyObservations{
+		values:   make(map[string]map[string]string),
+		answered: make(map[string]map[string]bool),
+	}
+}
+
+// observe records the value of a key at a peer, an empty value meaning the peer does not have the key
+func (o *keyObservations) observe(key, peerURL, value string) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	if o.answered[key] == nil {
+		o.answered[key] = make(map[string]bool)
+		o.values[key] = make(map[string]string)
+	}
+	o.answered[key][peerURL] = true
+	if value != "" {
+		o.values[key][peerURL] = value
+	}
+}
+
+// observeAll records the keys a peer has out of all the keys of some kind, the peer not having the keys other peers have
+func (o *keyObservations) observeAll(peerURL string, values map[string]string) {
+	for key, value := range values {
+		o.observe(key, peerURL, value)
+	}
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	o.allPeers = append(o.allPeers, peerURL)
+}
+
+// observed tells whether a key was read already
+func (o *keyObservations) observed(key string) bool {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	return o.answered[key] != nil
+}
+
+func (o *keyObservations) fail(err error) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	o.errors = append(o.errors, err.Error())
+}
+
+// checkConsistency compares the ledgers of the peers of the channel: their heights and current block hashes,
+// and the values of the keys requested. Keys read with everything are not read again one at a time.
+//
+func checkConsistency(ctx context.Context, req api.ConsistencyCheckRequest) (report *api.ConsistencyReport, err error) {
+	ctx, span := utils.StartSpan(ctx, "consistency_check")
+	defer func() { endSpan(span, err) }()
+
+	if req.SettleSeconds > 0 {
+		select {
+		case <-time.After(time.Duration(req.SettleSeconds) * time.Second):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	infos, err := fc.LedgerInfo(ConsortiumChannelID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the ledger info of the peers: %s", err)
+	}
+	report = &api.ConsistencyReport{Peers: []api.PeerLedger{}}
+	compareLedgers(report, infos)
+
+	var peerURLs []string
+	for _, info := range infos {
+		peerURLs = append(peerURLs, info.Peer)
+	}
+
+	observations := newKeyObservations()
+	if req.Everything {
+		readEverythingAtPeers(peerURLs, observations)
+	}
+	keys := append([]string(nil), req.Keys...)
+	for _, batchID := range req.BatchIDs {
+		keys = append(keys, batchID+ledgerKeyBatchResults)
+	}
+	for _, key := range dedupKeys(keys) {
+		if err = ctx.Err(); err != nil {
+			return nil, err
+		}
+		if observations.observed(key) {
+			continue
+		}
+		readKeyAtPeers(key, peerURLs, observations)
+	}
+	compareKeys(report, observations)
+
+	report.Consistent = len(report.DifferingKeys) == 0 && len(report.ForkedBlocks) == 0
+	span.SetAttributes(
+		"consistency.consistent", report.Consistent,
+		"consistency.keys", report.KeysChecked,
+		"consistency.differing_keys", len(report.DifferingKeys),
+	)
+	return report, nil
+}
+
+// checkConsistency compares the ledgers of all peers once the transfers of a batch run completed,
+// including all owners and marbles, hence those of the run
+func (tg *TransfersGenerator) checkConsistency(ctx context.Context) {
+	if ctx.Err() != nil {
+		tg.log.Infof("batch run cancelled, ledgers of the peers will not be compared")
+		return
+	}
+
+	req := *tg.request.ConsistencyCheck
+	req.Everything = true
+	report, err := checkConsistency(ctx, req)
+	if err != nil {
+		tg.log.Errorf("failed to compare the ledgers of the peers: %s", err)
+		return
+	}
+	if !report.Consistent {
+		tg.log.Warningf("ledgers of the peers differ after batch run %s: %d differing keys, %d forked blocks", tg.batchRunID, len(report.DifferingKeys), len(report.ForkedBlocks))
+	}
+	tg.consistency = report
+}
+
+// compareLedgers reports the peers below the highest ledger height, and the peers at the same height with different current blocks
+func compareLedgers(report *api.ConsistencyReport, infos []fabricclient.PeerLedgerInfo) {
+	var maxHeight uint64
+	for _, info := range infos {
+		if info.Err == nil && info.Height > maxHeight {
+			maxHeight = info.Height
+		}
+	}
+
+	hashesByHeight := make(map[uint64]map[string][]string)
+	for _, info := range infos {
+		peer := api.PeerLedger{Peer: info.Peer}
+		if info.Err != nil {
+			peer.Error = info.Err.Error()
+			report.Errors = append(report.Errors, peer.Error)
+			report.Peers = append(report.Peers, peer)
+			continue
+		}
+		peer.Height = info.Height
+		peer.CurrentBlockHash = hex.EncodeToString(info.CurrentBlockHash)
+		peer.BlocksBehind = maxHeight - info.Height
+		if peer.BlocksBehind > 0 {
+			report.LaggingPeers = append(report.LaggingPeers, info.Peer)
+		}
+		report.Peers = append(report.Peers, peer)
+
+		if hashesByHeight[info.Height] == nil {
+			hashesByHeight[info.Height] = make(map[string][]string)
+		}
+		hashesByHeight[info.Height][peer.CurrentBlockHash] = append(hashesByHeight[info.Height][peer.CurrentBlockHash], info.Peer)
+	}
+
+	for height, hashes := range hashesByHeight {
+		if len(hashes) < 2 {
+			continue
+		}
+		divergence := api.BlockDivergence{Height: height}
+		for hash, peers := range hashes {
+			divergence.Variants = append(divergence.Variants, api.ValueVariant{Value: hash, Peers: peers})
+		}
+		sortVariants(divergence.Variants)
+		report.ForkedBlocks = append(report.ForkedBlocks, divergence)
+	}
+	sort.Slice(report.ForkedBlocks, func(i, j int) bool { return report.ForkedBlocks[i].Height < report.ForkedBlocks[j].Height })
+}
+
+// readEverythingAtPeers reads all marbles and owners at each peer
+func readEverythingAtPeers(peerURLs []string, observations *keyObservations) {
+	var wg sync.WaitGroup
+	for _, peerURL := range peerURLs {
+		wg.Add(1)
+		go func(peerURL string) {
+			defer wg.Done()
+			resp, err := fc.QueryCCAtPeer(1, ConsortiumChannelID, MarblesCC, []string{"read_everything"}, nil, peerURL)
+			if err != nil {
+				observations.fail(fmt.Errorf("failed to read everything at %s: %s", peerURL, err))
+				return
+			}
+			var everything struct {
+				Owners  []json.RawMessage `json:"owners"`
+				Marbles []json.RawMessage `json:"marbles"`
+			}
+			if err := json.Unmarshal(resp.Payload, &everything); err != nil {
+				observations.fail(fmt.Errorf("failed to unmarshal everything read at %s: %s", peerURL, err))
+				return
+			}
+
+			found := make(map[string]string)
+			for _, entity := range append(everything.Owners, everything.Marbles...) {
+				var id struct {
+					Id string `json:"id"`
+				}
+				if err := json.Unmarshal(entity, &id); err != nil || id.Id == "" {
+					observations.fail(fmt.Errorf("failed to get the id of an entity read at %s: %s", peerURL, entity))
+					continue
+				}
+				found[id.Id] = string(entity)
+			}
+			observations.observeAll(peerURL, found)
+		}(peerURL)
+	}
+	wg.Wait()
+
+	// keys only some peers have are missing at the others
+	observations.lock.Lock()
+	defer observations.lock.Unlock()
+	for key := range observations.answered {
+		for _, peerURL := range observations.allPeers {
+			observations.answered[key][peerURL] = true
+		}
+	}
+}
+
+// readKeyAtPeers reads a key at each peer
+func readKeyAtPeers(key string, peerURLs []string, observations *keyObservations) {
+	var wg sync.WaitGroup
+	for _, peerURL := range peerURLs {
+		wg.Add(1)
+		go func(peerURL string) {
+			defer wg.Done()
+			resp, err := fc.QueryCCAtPeer(1, ConsortiumChannelID, MarblesCC, []string{"read", key}, nil, peerURL)
+			if err != nil {
+				observations.fail(fmt.Errorf("failed to read %s at %s: %s", key, peerURL, err))
+				return
+			}
+			observations.observe(key, peerURL, string(resp.Payload))
+		}(peerURL)
+	}
+	wg.Wait()
+}
+
+// compareKeys reports the keys whose values differ across the peers that answered
+func compareKeys(report *api.ConsistencyReport, observations *keyObservations) {
+	report.Errors = append(report.Errors, observations.errors...)
+	report.KeysChecked = len(observations.answered)
+
+	for key, peers := range observations.answered {
+		peersByValue := make(map[string][]string)
+		var missing []string
+		for peerURL := range peers {
+			if value, ok := observations.values[key][peerURL]; ok {
+				peersByValue[value] = append(peersByValue[value], peerURL)
+			} else {
+				missing = append(missing, peerURL)
+			}
+		}
+		if len(peersByValue) == 0 || (len(peersByValue) == 1 && len(missing) == 0) {
+			continue
+		}
+
+		divergence := api.KeyDivergence{Key: key}
+		for value, peers := range peersByValue {
+			divergence.Variants = append(divergence.Variants, api.ValueVariant{Value: value, Peers: peers})
+		}
+		if len(missing) > 0 {
+			divergence.Variants = append(divergence.Variants, api.ValueVariant{Missing: true, Peers: missing})
+		}
+		sortVariants(divergence.Variants)
+		report.DifferingKeys = append(report.DifferingKeys, divergence)
+	}
+	sort.Slice(report.DifferingKeys, func(i, j int) bool { return report.DifferingKeys[i].Key < report.DifferingKeys[j].Key })
+}
+
+// sortVariants sorts variants by their first peer, sorting their peers first
+func sortVariants(variants []api.ValueVariant) {
+	for _, variant := range variants {
+		sort.Strings(variant.Peers)
+	}
+	sort.Slice(variants, func(i, j int) bool { return variants[i].Peers[0] < variants[j].Peers[0] })
+}
+
+func dedupKeys(keys []string) []string {
+	seen := make(map[string]bool)
+	var deduped []string
+	for _, key := range keys {
+		if key != "" && !seen[key] {
+			seen[key] = true
+			deduped = append(deduped, key)
+		}
+	}
+	sort.Strings(deduped)
+	return deduped
+}
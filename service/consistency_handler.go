@@ -0,0 +1,46 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/securekey/marbles-perf/api"
+)
+
+// consistencyCheck compares the ledgers of the peers of the channel, all marbles and owners being compared if no key is requested
+//
+func consistencyCheck(w http.ResponseWriter, r *http.Request) {
+	if err := checkFabricClient(); err != nil {
+		writeErrorResponse(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+
+	payload, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, "failed to read request body: %s", err)
+		return
+	}
+	var req api.ConsistencyCheckRequest
+	if len(payload) > 0 {
+		if err := json.Unmarshal(payload, &req); err != nil {
+			writeErrorResponse(w, http.StatusBadRequest, "failed to parse payload json: %s", err)
+			return
+		}
+	}
+	if len(req.Keys) == 0 && len(req.BatchIDs) == 0 {
+		req.Everything = true
+	}
+
+	report, err := checkConsistency(r.Context(), req)
+	if err != nil {
+		writeErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSONResponse(w, http.StatusOK, report)
+}
@@ -0,0 +1,32 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"net/http"
+
+	fabricclient "github.com/securekey/marbles-perf/fabric-client"
+)
+
+// ccErrorStatus returns the HTTP status answering a request that failed because of err, eg. from a chaincode call
+//
+func ccErrorStatus(err error) int {
+	switch {
+	case fabricclient.IsMVCCConflict(err):
+		// the transaction lost against a concurrent update of the same keys, it can be submitted again
+		return http.StatusConflict
+	case fabricclient.IsTimeout(err):
+		return http.StatusGatewayTimeout
+	case fabricclient.IsEndorsementMismatch(err):
+		// peers disagree on the outcome of the call
+		return http.StatusBadGateway
+	case fabricclient.IsChaincodeError(err):
+		// the request was understood but rejected by the chaincode, eg. an unknown owner
+		return http.StatusUnprocessableEntity
+	default:
+		return http.StatusInternalServerError
+	}
+}
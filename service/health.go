@@ -0,0 +1,143 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/securekey/marbles-perf/api"
+	fabricclient "github.com/securekey/marbles-perf/fabric-client"
+	"github.com/spf13/viper"
+)
+
+const (
+	configReadinessTimeoutSeconds  = "http.server.readiness.timeout_seconds"
+	defaultReadinessTimeoutSeconds = 5
+
+	healthOK      = "ok"
+	healthFailed  = "failed"
+	healthTimeout = "timeout"
+
+	// key read by the readiness chaincode query, it does not need to exist
+	readinessProbeKey = "readiness_probe"
+)
+
+// readinessCheck is a named check of the readiness of the service
+type readinessCheck struct {
+	name  string
+	check func(ctx context.Context) error
+}
+
+var readinessChecks = []readinessCheck{
+	{name: "fabric_client", check: func(ctx context.Context) error { return checkFabricClient() }},
+	{name: "channel_client", check: checkChannelClient},
+	{name: "chaincode_query", check: checkChaincodeQuery},
+	{name: "schedules", check: checkSchedules},
+}
+
+// healthz tells whether the service is alive, ie. able to serve requests
+//
+func healthz(w http.ResponseWriter, r *http.Request) {
+	writeJSONResponse(w, http.StatusOK, api.HealthResponse{Status: healthOK})
+}
+
+// readyz tells whether the service is ready to run transfers.
+// Checks run concurrently within the readiness timeout, and a check not completing in time is reported as timed out
+// without waiting any longer for it.
+//
+func readyz(w http.ResponseWriter, r *http.Request) {
+	timeoutSeconds := viper.GetInt(configReadinessTimeoutSeconds)
+	if timeoutSeconds <= 0 {
+		timeoutSeconds = defaultReadinessTimeoutSeconds
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), time.Duration(timeoutSeconds)*time.Second)
+	defer cancel()
+
+	type checkResult struct {
+		index int
+		check api.HealthCheck
+	}
+	// buffered so that checks completing after the timeout do not block
+	results := make(chan checkResult, len(readinessChecks))
+	start := time.Now()
+	for i, c := range readinessChecks {
+		go func(index int, c readinessCheck) {
+			check := api.HealthCheck{Name: c.name, Status: healthOK}
+			if err := c.check(ctx); err != nil {
+				check.Status = healthFailed
+				check.Error = err.Error()
+			}
+			check.DurationSeconds = roundSeconds(time.Since(start))
+			results <- checkResult{index: index, check: check}
+		}(i, c)
+	}
+
+	response := api.HealthResponse{Status: healthOK, Checks: make([]api.HealthCheck, len(readinessChecks))}
+	completed := make([]bool, len(readinessChecks))
+wait:
+	for pending := len(readinessChecks); pending > 0; pending-- {
+		select {
+		case result := <-results:
+			response.Checks[result.index] = result.check
+			completed[result.index] = true
+		case <-ctx.Done():
+			break wait
+		}
+	}
+
+	for i, c := range readinessChecks {
+		if !completed[i] {
+			response.Checks[i] = api.HealthCheck{
+				Name:            c.name,
+				Status:          healthTimeout,
+				Error:           fmt.Sprintf("check did not complete within %d seconds", timeoutSeconds),
+				DurationSeconds: roundSeconds(time.Since(start)),
+			}
+		}
+		if response.Checks[i].Status != healthOK {
+			response.Status = healthFailed
+		}
+	}
+
+	status := http.StatusOK
+	if response.Status != healthOK {
+		status = http.StatusServiceUnavailable
+	}
+	writeJSONResponse(w, status, response)
+}
+
+func checkFabricClient() error {
+	if fc == nil {
+		return fmt.Errorf("fabric client not initialized")
+	}
+	return nil
+}
+
+func checkChannelClient(ctx context.Context) error {
+	chClient, err := fc.ChannelClient(ConsortiumChannelID)
+	if err != nil {
+		return err
+	}
+	fc.CloseChannelClient(chClient)
+	return nil
+}
+
+// checkChaincodeQuery makes a single attempt at a chaincode query, cancelled when ctx is done
+func checkChaincodeQuery(ctx context.Context) error {
+	_, err := fc.QueryCCContext(ctx, ConsortiumChannelID, MarblesCC, []string{"read", readinessProbeKey}, nil, fabricclient.WithMaxAttempts(1))
+	return err
+}
+
+// checkSchedules tells whether the schedules stored on the ledger were loaded
+func checkSchedules(ctx context.Context) error {
+	if !scheduler.isLoaded() {
+		return errSchedulesNotLoaded
+	}
+	return nil
+}
@@ -0,0 +1,29 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"fmt"
+)
+
+// writeLedgerValue writes a value to the ledger under the given key using the generic write function of marbles cc
+//
+func writeLedgerValue(key, value string) error {
+	if _, err := fc.InvokeCC(ConsortiumChannelID, MarblesCC, []string{"write", key, value}, nil); err != nil {
+		return fmt.Errorf("failed to write to ledger: %s: %s", key, err)
+	}
+	return nil
+}
+
+// readLedgerValue reads the value stored under the given key, an empty value is returned if the key does not exist
+//
+func readLedgerValue(key string) ([]byte, error) {
+	resp, err := fc.QueryCC(1, ConsortiumChannelID, MarblesCC, []string{"read", key}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from ledger: %s: %s", key, err)
+	}
+	return resp.Payload, nil
+}
@@ -0,0 +1,90 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/securekey/marbles-perf/api"
+	"github.com/securekey/marbles-perf/utils"
+)
+
+// getLogLevels returns the default log level and the levels of modules having their own
+//
+func getLogLevels(w http.ResponseWriter, r *http.Request) {
+	writeJSONResponse(w, http.StatusOK, currentLogLevels())
+}
+
+// setLogLevel changes the default log level or the level of a module, optionally for a limited time
+//
+func setLogLevel(w http.ResponseWriter, r *http.Request) {
+	payload, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, "failed to read request body: %s", err)
+		return
+	}
+
+	var req api.SetLogLevelRequest
+	if err := json.Unmarshal(payload, &req); err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, "failed to parse payload json: %s", err)
+		return
+	}
+	if req.DurationSeconds < 0 {
+		writeErrorResponse(w, http.StatusBadRequest, "durationSeconds must not be negative")
+		return
+	}
+
+	duration := time.Duration(req.DurationSeconds) * time.Second
+	if err := utils.SetLogLevel(req.Module, req.Level, duration); err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if duration > 0 {
+		logger.Infof("log level of module '%s' set to %s for %s", req.Module, req.Level, duration)
+	} else {
+		logger.Infof("log level of module '%s' set to %s", req.Module, req.Level)
+	}
+
+	writeJSONResponse(w, http.StatusOK, currentLogLevels())
+}
+
+// resetLogLevel removes the level of a module, which then follows the default level
+//
+func resetLogLevel(w http.ResponseWriter, r *http.Request) {
+	module := mux.Vars(r)["module"]
+	if err := utils.ResetLogLevel(module); err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	logger.Infof("log level of module '%s' reset to the default level", module)
+
+	writeJSONResponse(w, http.StatusOK, currentLogLevels())
+}
+
+func currentLogLevels() api.LogLevels {
+	levels := api.LogLevels{Modules: []api.LogLevel{}}
+	for _, moduleLevel := range utils.GetLogLevels() {
+		level := api.LogLevel{
+			Module: moduleLevel.Module,
+			Level:  utils.LogLevelName(moduleLevel.Level),
+		}
+		if !moduleLevel.RevertAt.IsZero() {
+			revertAt := moduleLevel.RevertAt
+			level.RevertAt = &revertAt
+		}
+
+		if level.Module == "" {
+			levels.Default = level
+		} else {
+			levels.Modules = append(levels.Modules, level)
+		}
+	}
+	return levels
+}
@@ -20,6 +20,7 @@ import (
 
 	"github.com/gorilla/mux"
 	"github.com/op/go-logging"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	fabclient "github.com/securekey/marbles-perf/fabric-client"
 	"github.com/securekey/marbles-perf/utils"
 	"github.com/spf13/viper"
@@ -45,16 +46,33 @@ func main() {
 		log.Fatalf("error setting up viper using config file and environmental variables: %v ", err)
 	}
 
-	utils.InitLogger()
+	if err := utils.InitLogger(); err != nil {
+		log.Fatalf("failed to initialize logging: %s", err)
+	}
+
+	if err := utils.InitTracing(); err != nil {
+		log.Fatalf("failed to initialize tracing: %s", err)
+	}
 
 	fc, err = fabclient.NewClient()
 	if err != nil {
 		log.Fatalf("failed to initialize fabric client: %s", err)
 	}
 
+	authz, err := newAuthorizer()
+	if err != nil {
+		log.Fatalf("failed to initialize authorization: %s", err)
+	}
+
 	r := mux.NewRouter()
+	r.Use(instrumentHandler, traceHandler, authz.authorize)
 	// ping
 	r.HandleFunc("/hello", handleHello)
+	// liveness and readiness
+	r.HandleFunc("/healthz", healthz).Methods(http.MethodGet)
+	r.HandleFunc("/readyz", readyz).Methods(http.MethodGet)
+	// prometheus metrics
+	r.Handle("/metrics", promhttp.Handler()).Methods(http.MethodGet)
 	// CRUD
 	r.HandleFunc("/marble", createMarble).Methods(http.MethodPost)
 	r.HandleFunc("/marble/{id}", getMarble).Methods(http.MethodGet)
@@ -67,6 +85,33 @@ func main() {
 	// batch (random) transfers
 	r.HandleFunc("/batch_run", initBatchTransfers).Methods(http.MethodPost)
 	r.HandleFunc("/batch_run/{id}", fetchBatchResults).Methods(http.MethodGet)
+	r.HandleFunc("/batch_run/{id}", cancelBatchRun).Methods(http.MethodDelete)
+	r.HandleFunc("/batch_run/{id}/comparison", fetchBatchComparison).Methods(http.MethodGet)
+	r.HandleFunc("/batch_run/{id}/profiles/{name}", fetchBatchProfile).Methods(http.MethodGet)
+
+	// scenario baselines
+	r.HandleFunc("/baseline/{scenario}", setBaseline).Methods(http.MethodPut)
+	r.HandleFunc("/baseline/{scenario}", getBaseline).Methods(http.MethodGet)
+
+	// scheduled (recurring) batch runs
+	scheduler = newBatchScheduler(viper.GetInt(configScheduleHistorySize))
+	scheduler.loadInBackground()
+	r.HandleFunc("/schedule", createSchedule).Methods(http.MethodPost)
+	r.HandleFunc("/schedule", listSchedules).Methods(http.MethodGet)
+	r.HandleFunc("/schedule/{id}", getSchedule).Methods(http.MethodGet)
+	r.HandleFunc("/schedule/{id}", deleteSchedule).Methods(http.MethodDelete)
+	r.HandleFunc("/schedule/{id}/history", getScheduleHistory).Methods(http.MethodGet)
+
+	// admin
+	r.HandleFunc("/admin/logging", getLogLevels).Methods(http.MethodGet)
+	r.HandleFunc("/admin/logging", setLogLevel).Methods(http.MethodPut)
+	r.HandleFunc("/admin/logging/{module:.+}", resetLogLevel).Methods(http.MethodDelete)
+	r.HandleFunc("/admin/peers", getPeerHealth).Methods(http.MethodGet)
+	r.HandleFunc("/consistency_check", consistencyCheck).Methods(http.MethodPost)
+	registerPprofHandlers(r)
+	if err := authz.checkRoutes(r); err != nil {
+		log.Fatalf("failed to initialize authorization: %s", err)
+	}
 
 	// Seed the random generator so we get different values each time
 	rand.Seed(time.Now().UTC().UnixNano())
@@ -77,7 +122,7 @@ func main() {
 		WriteTimeout: 30 * time.Second,
 		ReadTimeout:  30 * time.Second,
 	}
-	log.Fatal(srv.ListenAndServe())
+	log.Fatal(listenAndServe(srv))
 }
 
 func handleHello(w http.ResponseWriter, r *http.Request) {
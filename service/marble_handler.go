@@ -6,6 +6,7 @@ SPDX-License-Identifier: Apache-2.0
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"io/ioutil"
 	"net/http"
@@ -48,15 +49,15 @@ func createOwner(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	response, err := doCreateOwner(owner)
+	response, err := doCreateOwner(r.Context(), owner)
 	if err != nil {
-		writeErrorResponse(w, http.StatusInternalServerError, err.Error())
+		writeErrorResponse(w, ccErrorStatus(err), err.Error())
 		return
 	}
 	writeJSONResponse(w, http.StatusOK, response)
 }
 
-func doCreateOwner(owner api.Owner) (resp api.Response, err error) {
+func doCreateOwner(ctx context.Context, owner api.Owner) (resp api.Response, err error) {
 	id := owner.Id
 	if id == "" {
 		id, err = utils.GenerateRandomAlphaNumericString(31)
@@ -75,9 +76,9 @@ func doCreateOwner(owner api.Owner) (resp api.Response, err error) {
 	}
 
 	var data *fabricclient.CCResponse
-	data, err = fc.InvokeCC(ConsortiumChannelID, MarblesCC, args, nil)
+	data, err = invokeCC(ctx, args)
 	if err != nil {
-		err = fmt.Errorf("cc invoke failed: %s: %v", err, args)
+		err = fmt.Errorf("cc invoke failed: %w: %v", err, args)
 		return
 	}
 
@@ -103,15 +104,18 @@ func createMarble(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	response, err := doCreateMarble(marble)
+	response, err := doCreateMarble(r.Context(), marble)
 	if err != nil {
-		writeErrorResponse(w, http.StatusInternalServerError, err.Error())
+		writeErrorResponse(w, ccErrorStatus(err), err.Error())
 		return
 	}
 	writeJSONResponse(w, http.StatusOK, response)
 }
 
-func doCreateMarble(marble api.Marble) (resp api.Response, err error) {
+func doCreateMarble(ctx context.Context, marble api.Marble, opts ...fabricclient.CallOption) (resp api.Response, err error) {
+	ctx, span := utils.StartSpan(ctx, "doCreateMarble")
+	defer func() { endSpan(span, err) }()
+
 	id := marble.Id
 	if id == "" {
 		id, err = utils.GenerateRandomAlphaNumericString(31)
@@ -121,6 +125,7 @@ func doCreateMarble(marble api.Marble) (resp api.Response, err error) {
 		}
 		id = "m" + id
 	}
+	span.SetAttributes("marble.id", id)
 
 	args := []string{
 		"init_marble",
@@ -136,9 +141,9 @@ func doCreateMarble(marble api.Marble) (resp api.Response, err error) {
 		args = append(args, marble.AdditionalData)
 	}
 
-	data, ccErr := fc.InvokeCC(ConsortiumChannelID, MarblesCC, args, nil)
+	data, ccErr := invokeCC(ctx, args, opts...)
 	if ccErr != nil {
-		err = fmt.Errorf("cc invoke failed: %s: %v", err, args)
+		err = fmt.Errorf("cc invoke failed: %w: %v", ccErr, args)
 		return
 	}
 
@@ -159,9 +164,9 @@ func deleteMarbleNoAuth(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	response, err := doDeleteMarbleNoAuth(id)
+	response, err := doDeleteMarbleNoAuth(r.Context(), id)
 	if err != nil {
-		writeErrorResponse(w, http.StatusBadRequest, err.Error())
+		writeErrorResponse(w, ccErrorStatus(err), err.Error())
 		return
 	}
 	writeJSONResponse(w, http.StatusOK, response)
@@ -169,16 +174,16 @@ func deleteMarbleNoAuth(w http.ResponseWriter, r *http.Request) {
 
 // doDeleteMarbleNoAuth deletes a marble without checking auth company
 //
-func doDeleteMarbleNoAuth(id string) (resp api.Response, err error) {
+func doDeleteMarbleNoAuth(ctx context.Context, id string, opts ...fabricclient.CallOption) (resp api.Response, err error) {
 
 	args := []string{
 		"delete_marble_noauth",
 		id,
 	}
 
-	data, ccErr := fc.InvokeCC(ConsortiumChannelID, MarblesCC, args, nil)
+	data, ccErr := invokeCC(ctx, args, opts...)
 	if ccErr != nil {
-		err = fmt.Errorf("cc invoke failed: %s: %v", err, args)
+		err = fmt.Errorf("cc invoke failed: %w: %v", ccErr, args)
 		return
 	}
 
@@ -204,26 +209,21 @@ func transfer(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	args := []string{
-		"set_owner",
-		transfer.MarbleId,
-		transfer.ToOwnerId,
-		transfer.AuthCompany,
-	}
-
-	data, err := fc.InvokeCC(ConsortiumChannelID, MarblesCC, args, nil)
+	response, err := doTransfer(r.Context(), transfer)
 	if err != nil {
-		writeErrorResponse(w, http.StatusInternalServerError, "cc invoke failed: %s: %v", err, args)
+		writeErrorResponse(w, ccErrorStatus(err), err.Error())
 		return
 	}
-	response := api.Response{
-		Id:   transfer.MarbleId,
-		TxId: data.FabricTxnID,
-	}
 	writeJSONResponse(w, http.StatusOK, response)
 }
 
-func doTransfer(transfer api.Transfer) (resp api.Response, err error) {
+func doTransfer(ctx context.Context, transfer api.Transfer, opts ...fabricclient.CallOption) (resp api.Response, err error) {
+	ctx, span := utils.StartSpan(ctx, "doTransfer", utils.WithAttributes(
+		"marble.id", transfer.MarbleId,
+		"marble.to_owner", transfer.ToOwnerId,
+	))
+	defer func() { endSpan(span, err) }()
+
 	args := []string{
 		"set_owner",
 		transfer.MarbleId,
@@ -231,33 +231,50 @@ func doTransfer(transfer api.Transfer) (resp api.Response, err error) {
 		transfer.AuthCompany,
 	}
 
-	data, err := fc.InvokeCC(ConsortiumChannelID, MarblesCC, args, nil)
+	data, err := invokeCC(ctx, args, opts...)
 	if err != nil {
-		err = fmt.Errorf("cc invoke failed: %s: %v", err, args)
+		resp.Retries = toAPIRetries(fabricclient.ErrorRetries(err))
+		err = fmt.Errorf("cc invoke failed: %w: %v", err, args)
 		return
 	}
 	resp = api.Response{
-		Id:   transfer.MarbleId,
-		TxId: data.FabricTxnID,
+		Id:        transfer.MarbleId,
+		TxId:      data.FabricTxnID,
+		Retries:   toAPIRetries(data.Retries),
+		Endorsers: data.Endorsers,
 	}
 	return
 }
 
+func toAPIRetries(retries []fabricclient.Retry) []api.Retry {
+	var apiRetries []api.Retry
+	for _, retry := range retries {
+		apiRetries = append(apiRetries, api.Retry{
+			Group:   retry.Group,
+			Code:    retry.Code,
+			Message: retry.Message,
+			Peers:   retry.Peers,
+		})
+	}
+	return apiRetries
+}
+
 // clearMarbles remove all marbles from ledger
 //
 func clearMarbles(w http.ResponseWriter, r *http.Request) {
-	response, err := doClearMarbles()
+	response, err := doClearMarbles(r.Context())
 	if err != nil {
-		writeErrorResponse(w, http.StatusInternalServerError, err.Error())
+		writeErrorResponse(w, ccErrorStatus(err), err.Error())
+		return
 	}
 	writeJSONResponse(w, http.StatusOK, response)
 }
 
-func doClearMarbles() (response api.ClearMarblesResponse, err error) {
+func doClearMarbles(ctx context.Context) (response api.ClearMarblesResponse, err error) {
 	args := []string{"clear_marbles"}
-	data, ccErr := fc.InvokeCC(ConsortiumChannelID, MarblesCC, args, nil)
+	data, ccErr := invokeCC(ctx, args)
 	if ccErr != nil {
-		err = fmt.Errorf("cc invoke failed: %s: %v", ccErr, args)
+		err = fmt.Errorf("cc invoke failed: %w: %v", ccErr, args)
 		return
 	}
 
@@ -269,7 +286,7 @@ func doClearMarbles() (response api.ClearMarblesResponse, err error) {
 	return
 }
 
-// getEntity retrieves an existing entity
+// getEntity retrieves an existing entity, from the peers accepted by the peer filter named by the peerFilter query parameter if set
 //
 func getEntity(w http.ResponseWriter, r *http.Request, entity interface{}) {
 	vars := mux.Vars(r)
@@ -279,9 +296,18 @@ func getEntity(w http.ResponseWriter, r *http.Request, entity interface{}) {
 		return
 	}
 
-	data, err := doGetEntity(id, entity)
+	var opts []fabricclient.CallOption
+	if name := r.URL.Query().Get("peerFilter"); name != "" {
+		if _, err := fc.PeerFilter(name); err != nil {
+			writeErrorResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		opts = append(opts, fabricclient.WithNamedPeerFilter(name))
+	}
+
+	data, err := doGetEntity(r.Context(), id, entity, opts...)
 	if err != nil {
-		writeErrorResponse(w, http.StatusInternalServerError, err.Error())
+		writeErrorResponse(w, ccErrorStatus(err), err.Error())
 		return
 	}
 
@@ -293,15 +319,15 @@ func getEntity(w http.ResponseWriter, r *http.Request, entity interface{}) {
 	writeJSONResponse(w, http.StatusOK, entity)
 }
 
-func doGetEntity(id string, entity interface{}) ([]byte, error) {
+func doGetEntity(ctx context.Context, id string, entity interface{}, opts ...fabricclient.CallOption) ([]byte, error) {
 	args := []string{
 		"read",
 		id,
 	}
 
-	data, err := fc.QueryCC(0, ConsortiumChannelID, MarblesCC, args, nil)
+	data, err := queryCC(ctx, args, opts...)
 	if err != nil {
-		return nil, fmt.Errorf("cc invoke failed: %s", err)
+		return nil, fmt.Errorf("cc invoke failed: %w", err)
 	}
 
 	payloadJSON := data.Payload
@@ -314,9 +340,9 @@ func doGetEntity(id string, entity interface{}) ([]byte, error) {
 	return payloadJSON, nil
 }
 
-func doGetOwner(id string) (*api.Owner, error) {
+func doGetOwner(ctx context.Context, id string) (*api.Owner, error) {
 	var owner api.Owner
-	if data, err := doGetEntity(id, &owner); err != nil {
+	if data, err := doGetEntity(ctx, id, &owner); err != nil {
 		return nil, err
 	} else if len(data) == 0 {
 		return nil, nil
@@ -324,9 +350,9 @@ func doGetOwner(id string) (*api.Owner, error) {
 	return &owner, nil
 }
 
-func doGetMarble(id string) (*api.Marble, error) {
+func doGetMarble(ctx context.Context, id string) (*api.Marble, error) {
 	var marble api.Marble
-	if data, err := doGetEntity(id, &marble); err != nil {
+	if data, err := doGetEntity(ctx, id, &marble); err != nil {
 		return nil, err
 	} else if len(data) == 0 {
 		return nil, nil
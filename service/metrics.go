@@ -0,0 +1,136 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	metricsNamespace = "marbles_perf"
+
+	resultSuccess = "success"
+	resultFailure = "failure"
+)
+
+var (
+	httpRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "http",
+			Name:      "requests_total",
+			Help:      "Number of HTTP requests by route, method and status code.",
+		},
+		[]string{"route", "method", "code"},
+	)
+
+	httpDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "http",
+			Name:      "request_duration_seconds",
+			Help:      "Latency of HTTP requests by route and method.",
+			Buckets:   prometheus.DefBuckets,
+		},
+		[]string{"route", "method"},
+	)
+
+	httpInFlight = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "http",
+			Name:      "requests_in_flight",
+			Help:      "Number of HTTP requests in progress.",
+		},
+	)
+
+	batchRunsInProgress = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "batch",
+			Name:      "runs_in_progress",
+			Help:      "Number of batch runs in progress.",
+		},
+	)
+
+	batchWorkersActive = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "batch",
+			Name:      "workers_active",
+			Help:      "Number of batch run workers in progress, across all batch runs.",
+		},
+	)
+
+	batchTransfers = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "batch",
+			Name:      "transfers_total",
+			Help:      "Number of marble transfers made by batch run workers by result.",
+		},
+		[]string{"result"},
+	)
+
+	batchTransferDuration = prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "batch",
+			Name:      "transfer_duration_seconds",
+			Help:      "Latency of successful marble transfers made by batch run workers.",
+			Buckets:   []float64{.05, .1, .25, .5, 1, 2, 3, 5, 10, 20, 30, 60},
+		},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(httpRequests, httpDuration, httpInFlight, batchRunsInProgress, batchWorkersActive, batchTransfers, batchTransferDuration)
+}
+
+// statusRecorder captures the status code written by a handler
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// instrumentHandler is a mux middleware recording request metrics labeled by route template
+//
+func instrumentHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := routeTemplate(r)
+
+		httpInFlight.Inc()
+		defer httpInFlight.Dec()
+
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		start := time.Now()
+		next.ServeHTTP(recorder, r)
+
+		httpDuration.WithLabelValues(route, r.Method).Observe(time.Since(start).Seconds())
+		httpRequests.WithLabelValues(route, r.Method, strconv.Itoa(recorder.status)).Inc()
+	})
+}
+
+// routeTemplate returns the path template of the route matched by mux for the request
+//
+func routeTemplate(r *http.Request) string {
+	if current := mux.CurrentRoute(r); current != nil {
+		if template, err := current.GetPathTemplate(); err == nil {
+			return template
+		}
+	}
+	return "unknown"
+}
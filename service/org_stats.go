@@ -0,0 +1,51 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"sort"
+	"time"
+
+	"github.com/securekey/marbles-perf/api"
+)
+
+// orgStats breaks the transfers of a batch run down by organization of the workers, nil unless organizations were assigned
+//
+func orgStats(perfDataArray []WorkerPerfData, runTime time.Duration) []api.OrgStats {
+	byOrg := make(map[string][]WorkerPerfData)
+	for _, perfData := range perfDataArray {
+		if perfData.org != "" {
+			byOrg[perfData.org] = append(byOrg[perfData.org], perfData)
+		}
+	}
+
+	var stats []api.OrgStats
+	for org, orgPerfData := range byOrg {
+		s := api.OrgStats{Org: org, Workers: len(orgPerfData)}
+		var transferTimes []time.Duration
+		for _, perfData := range orgPerfData {
+			s.Successes += perfData.successes
+			s.Failures += perfData.failures
+			for _, duration := range perfData.transferTimes {
+				// durations are only captured for successes
+				if duration > 0 {
+					transferTimes = append(transferTimes, duration)
+				}
+			}
+		}
+		latencies := summarizeLatencies(transferTimes)
+		s.AverageTransferSeconds = latencies.average
+		s.P50TransferSeconds = latencies.p50
+		s.P90TransferSeconds = latencies.p90
+		s.P99TransferSeconds = latencies.p99
+		if runTime > 0 {
+			s.Throughput = roundMillis(float64(s.Successes) / runTime.Seconds())
+		}
+		stats = append(stats, s)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Org < stats[j].Org })
+	return stats
+}
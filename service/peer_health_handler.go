@@ -0,0 +1,46 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"net/http"
+
+	"github.com/securekey/marbles-perf/api"
+)
+
+// getPeerHealth returns the health of the peers selected so far, including those taken out of selection
+//
+func getPeerHealth(w http.ResponseWriter, r *http.Request) {
+	if err := checkFabricClient(); err != nil {
+		writeErrorResponse(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+
+	peers := []api.PeerHealth{}
+	for _, health := range fc.PeerHealth() {
+		peer := api.PeerHealth{
+			Peer:                health.Peer,
+			Circuit:             health.Circuit,
+			Outstanding:         health.Outstanding,
+			LatencyMillis:       roundMillis(health.LatencyMillis),
+			Proposals:           health.Proposals,
+			Failures:            health.Failures,
+			ConsecutiveFailures: health.ConsecutiveFailures,
+			LastError:           health.LastError,
+			Ejections:           health.Ejections,
+		}
+		if !health.LastErrorAt.IsZero() {
+			lastErrorAt := health.LastErrorAt
+			peer.LastErrorAt = &lastErrorAt
+		}
+		if !health.EjectedUntil.IsZero() {
+			ejectedUntil := health.EjectedUntil
+			peer.EjectedUntil = &ejectedUntil
+		}
+		peers = append(peers, peer)
+	}
+	writeJSONResponse(w, http.StatusOK, peers)
+}
@@ -0,0 +1,88 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"sort"
+	"time"
+
+	"github.com/securekey/marbles-perf/api"
+	fabricclient "github.com/securekey/marbles-perf/fabric-client"
+)
+
+// peerPerfData is the perf data of a worker for a single endorsing peer
+type peerPerfData struct {
+	transferTimes []time.Duration // times of the successful transfers endorsed by the peer
+	failures      int
+	retries       int
+}
+
+func (w *MarbleWorker) peer(address string) *peerPerfData {
+	if w.perfData.peers == nil {
+		w.perfData.peers = make(map[string]*peerPerfData)
+	}
+	data, exists := w.perfData.peers[address]
+	if !exists {
+		data = &peerPerfData{}
+		w.perfData.peers[address] = data
+	}
+	return data
+}
+
+// recordPeers attributes a transfer to its endorsing peers if it succeeded, or to the peers its error comes from otherwise
+func (w *MarbleWorker) recordPeers(resp api.Response, duration time.Duration, err error) {
+	for _, retry := range resp.Retries {
+		for _, address := range retry.Peers {
+			w.peer(address).retries++
+		}
+	}
+
+	if err != nil {
+		for _, address := range fabricclient.ErrorPeers(err) {
+			w.peer(address).failures++
+		}
+		return
+	}
+	for _, address := range resp.Endorsers {
+		data := w.peer(address)
+		data.transferTimes = append(data.transferTimes, duration)
+	}
+}
+
+// peerStats merges the per peer perf data of all workers
+//
+func peerStats(perfDataArray []WorkerPerfData) []api.PeerStats {
+	merged := make(map[string]*peerPerfData)
+	for _, perfData := range perfDataArray {
+		for address, data := range perfData.peers {
+			m, exists := merged[address]
+			if !exists {
+				m = &peerPerfData{}
+				merged[address] = m
+			}
+			m.transferTimes = append(m.transferTimes, data.transferTimes...)
+			m.failures += data.failures
+			m.retries += data.retries
+		}
+	}
+
+	var stats []api.PeerStats
+	for address, data := range merged {
+		latencies := summarizeLatencies(data.transferTimes)
+		stats = append(stats, api.PeerStats{
+			Peer:                   address,
+			Endorsed:               latencies.count,
+			AverageTransferSeconds: latencies.average,
+			P50TransferSeconds:     latencies.p50,
+			P90TransferSeconds:     latencies.p90,
+			P99TransferSeconds:     latencies.p99,
+			Failures:               data.failures,
+			Retries:                data.retries,
+		})
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Peer < stats[j].Peer })
+	return stats
+}
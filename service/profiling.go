@@ -0,0 +1,257 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"path/filepath"
+	"runtime"
+	rtpprof "runtime/pprof"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/securekey/marbles-perf/api"
+	"github.com/securekey/marbles-perf/utils"
+	"github.com/spf13/viper"
+)
+
+const (
+	configPprofEnabled         = "http.server.pprof.enabled"
+	configProfilesDir          = "batch.profiles.dir"
+	configRuntimeSampleSeconds = "batch.runtime.sample_seconds"
+
+	defaultProfilesDirName      = "marbles-perf-profiles"
+	defaultRuntimeSampleSeconds = 1
+
+	cpuProfileName = "cpu.pprof"
+)
+
+// registerPprofHandlers serves the net/http/pprof endpoints under /debug/pprof if enabled in configuration
+//
+func registerPprofHandlers(r *mux.Router) {
+	if !viper.GetBool(configPprofEnabled) {
+		return
+	}
+	r.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	r.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	r.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	r.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	// the index also serves the named profiles, eg. /debug/pprof/heap
+	r.PathPrefix("/debug/pprof/").HandlerFunc(pprof.Index)
+}
+
+func profilesDir(batchID string) string {
+	dir := viper.GetString(configProfilesDir)
+	if dir == "" {
+		dir = filepath.Join(os.TempDir(), defaultProfilesDirName)
+	}
+	return filepath.Join(dir, batchID)
+}
+
+// fetchBatchProfile serves a profile captured during a batch run
+//
+func fetchBatchProfile(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, name := vars["id"], vars["name"]
+	if id != filepath.Base(id) || name != filepath.Base(name) {
+		writeErrorResponse(w, http.StatusBadRequest, "invalid batch id or profile name")
+		return
+	}
+
+	path := filepath.Join(profilesDir(id), name)
+	if _, err := os.Stat(path); err != nil {
+		writeErrorResponse(w, http.StatusNotFound, "profile %s of batch run %s not found", name, id)
+		return
+	}
+	w.Header().Set("content-type", "application/octet-stream")
+	w.Header().Set("content-disposition", fmt.Sprintf("attachment; filename=%s-%s", id, name))
+	http.ServeFile(w, r, path)
+}
+
+// runProfiler samples runtime statistics during a batch run, and captures the profiles requested
+type runProfiler struct {
+	batchID string
+	request api.ProfileRequest
+	log     *utils.FieldLogger
+
+	dir        string
+	cpuProfile *os.File
+	heapCount  int
+	profiles   []string
+
+	stats        api.RuntimeStats
+	goroutineSum int
+	startNumGC   uint32
+	startPauseNs uint64
+	lastNumGC    uint32
+
+	stopCh chan struct{}
+	done   chan struct{}
+}
+
+// startRunProfiler starts sampling runtime statistics, along with the profiles requested if any
+//
+func startRunProfiler(batchID string, request *api.ProfileRequest, log *utils.FieldLogger) *runProfiler {
+	p := &runProfiler{
+		batchID: batchID,
+		log:     log,
+		dir:     profilesDir(batchID),
+		stopCh:  make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	if request != nil {
+		p.request = *request
+	}
+
+	var memStats runtime.MemStats
+	runtime.ReadMemStats(&memStats)
+	p.startNumGC = memStats.NumGC
+	p.startPauseNs = memStats.PauseTotalNs
+	p.lastNumGC = memStats.NumGC
+
+	if p.request.CPU || p.request.Heap {
+		if err := os.MkdirAll(p.dir, 0750); err != nil {
+			log.Errorf("failed to create profiles directory, no profile captured: %s", err)
+			p.request = api.ProfileRequest{}
+		}
+	}
+	if p.request.CPU {
+		p.startCPUProfile()
+	}
+	if p.request.Heap {
+		p.writeHeapProfile()
+	}
+
+	go p.sample()
+	return p
+}
+
+func (p *runProfiler) startCPUProfile() {
+	file, err := os.Create(filepath.Join(p.dir, cpuProfileName))
+	if err != nil {
+		p.log.Errorf("failed to create CPU profile: %s", err)
+		return
+	}
+	// only one CPU profile can be captured at a time, eg. a concurrent batch run may hold it
+	if err := rtpprof.StartCPUProfile(file); err != nil {
+		p.log.Errorf("failed to start CPU profile: %s", err)
+		file.Close()
+		os.Remove(file.Name())
+		return
+	}
+	p.cpuProfile = file
+}
+
+func (p *runProfiler) writeHeapProfile() {
+	p.heapCount++
+	name := fmt.Sprintf("heap-%d.pprof", p.heapCount)
+	file, err := os.Create(filepath.Join(p.dir, name))
+	if err != nil {
+		p.log.Errorf("failed to create heap profile: %s", err)
+		return
+	}
+	defer file.Close()
+	if err := rtpprof.WriteHeapProfile(file); err != nil {
+		p.log.Errorf("failed to write heap profile: %s", err)
+		return
+	}
+	p.addProfile(name)
+}
+
+func (p *runProfiler) addProfile(name string) {
+	p.profiles = append(p.profiles, fmt.Sprintf("/batch_run/%s/profiles/%s", p.batchID, name))
+}
+
+func (p *runProfiler) sample() {
+	defer close(p.done)
+
+	sampleSeconds := viper.GetInt(configRuntimeSampleSeconds)
+	if sampleSeconds <= 0 {
+		sampleSeconds = defaultRuntimeSampleSeconds
+	}
+	sampleTicker := time.NewTicker(time.Duration(sampleSeconds) * time.Second)
+	defer sampleTicker.Stop()
+
+	var heapTicks <-chan time.Time
+	if p.request.Heap && p.request.HeapIntervalSeconds > 0 {
+		heapTicker := time.NewTicker(time.Duration(p.request.HeapIntervalSeconds) * time.Second)
+		defer heapTicker.Stop()
+		heapTicks = heapTicker.C
+	}
+
+	p.sampleRuntime()
+	for {
+		select {
+		case <-sampleTicker.C:
+			p.sampleRuntime()
+		case <-heapTicks:
+			p.writeHeapProfile()
+		case <-p.stopCh:
+			p.sampleRuntime()
+			return
+		}
+	}
+}
+
+// sampleRuntime samples the number of goroutines, the heap size and the pauses of garbage collections since the previous sample
+func (p *runProfiler) sampleRuntime() {
+	goroutines := runtime.NumGoroutine()
+	p.stats.Samples++
+	p.goroutineSum += goroutines
+	if goroutines > p.stats.MaxGoroutines {
+		p.stats.MaxGoroutines = goroutines
+	}
+
+	var memStats runtime.MemStats
+	runtime.ReadMemStats(&memStats)
+	if memStats.HeapAlloc > p.stats.MaxHeapAllocBytes {
+		p.stats.MaxHeapAllocBytes = memStats.HeapAlloc
+	}
+
+	// PauseNs is a circular buffer of the most recent pauses
+	newGCs := memStats.NumGC - p.lastNumGC
+	if newGCs > uint32(len(memStats.PauseNs)) {
+		newGCs = uint32(len(memStats.PauseNs))
+	}
+	for i := uint32(0); i < newGCs; i++ {
+		pause := memStats.PauseNs[(memStats.NumGC-i+uint32(len(memStats.PauseNs))-1)%uint32(len(memStats.PauseNs))]
+		if millis := durationMillis(time.Duration(pause)); millis > p.stats.MaxGCPauseMillis {
+			p.stats.MaxGCPauseMillis = millis
+		}
+	}
+	p.lastNumGC = memStats.NumGC
+	p.stats.GCCount = memStats.NumGC - p.startNumGC
+	p.stats.GCPauseTotalMillis = durationMillis(time.Duration(memStats.PauseTotalNs - p.startPauseNs))
+}
+
+// durationMillis returns the duration in milliseconds rounded to microseconds
+func durationMillis(d time.Duration) float64 {
+	return roundMillis(float64(d) / float64(time.Millisecond))
+}
+
+// stop stops sampling and capturing profiles, and returns the runtime statistics of the run
+//
+func (p *runProfiler) stop() *api.RuntimeStats {
+	close(p.stopCh)
+	<-p.done
+
+	if p.cpuProfile != nil {
+		rtpprof.StopCPUProfile()
+		p.cpuProfile.Close()
+		p.addProfile(cpuProfileName)
+	}
+	if p.request.Heap {
+		p.writeHeapProfile()
+	}
+
+	stats := p.stats
+	stats.AverageGoroutines = roundMillis(float64(p.goroutineSum) / float64(stats.Samples))
+	stats.Profiles = p.profiles
+	return &stats
+}
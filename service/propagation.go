@@ -0,0 +1,115 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/securekey/marbles-perf/api"
+)
+
+const (
+	defaultPropagationSampleEvery    = 10
+	defaultPropagationTimeoutSeconds = 30
+	defaultPropagationPollMillis     = 100
+)
+
+// propagationSampler measures when transfers become visible at every peer of the channel
+type propagationSampler struct {
+	peerURLs     []string
+	sampleEvery  int
+	timeout      time.Duration
+	pollInterval time.Duration
+}
+
+func newPropagationSampler(req api.PropagationRequest, peerURLs []string) *propagationSampler {
+	s := &propagationSampler{
+		peerURLs:     peerURLs,
+		sampleEvery:  req.SampleEvery,
+		timeout:      time.Duration(req.TimeoutSeconds) * time.Second,
+		pollInterval: time.Duration(req.PollMillis) * time.Millisecond,
+	}
+	if s.sampleEvery <= 0 {
+		s.sampleEvery = defaultPropagationSampleEvery
+	}
+	if s.timeout <= 0 {
+		s.timeout = defaultPropagationTimeoutSeconds * time.Second
+	}
+	if s.pollInterval <= 0 {
+		s.pollInterval = defaultPropagationPollMillis * time.Millisecond
+	}
+	return s
+}
+
+// sampled tells whether the given iteration of a worker is to be measured
+func (s *propagationSampler) sampled(iteration int) bool {
+	return iteration%s.sampleEvery == 0
+}
+
+// measure polls every peer until the transaction is committed there, and returns the spread between
+// the first and the last peer committing it. complete is false if a peer did not commit it before the timeout,
+// or if ctx is done first.
+//
+func (s *propagationSampler) measure(ctx context.Context, txID string) (spread time.Duration, complete bool) {
+	start := time.Now()
+	pending := append([]string(nil), s.peerURLs...)
+	var first, last time.Duration
+	for {
+		committed, err := fc.TransactionCommitted(ConsortiumChannelID, txID, pending)
+		if err != nil {
+			return 0, false
+		}
+		after := time.Since(start)
+		var remaining []string
+		for i, peerURL := range pending {
+			if !committed[i] {
+				remaining = append(remaining, peerURL)
+				continue
+			}
+			if first == 0 {
+				first = after
+			}
+			last = after
+		}
+		if len(remaining) == 0 {
+			return last - first, true
+		}
+		pending = remaining
+
+		if time.Since(start)+s.pollInterval > s.timeout {
+			return 0, false
+		}
+		select {
+		case <-time.After(s.pollInterval):
+		case <-ctx.Done():
+			return 0, false
+		}
+	}
+}
+
+// propagationSummary summarizes the propagation spreads measured by all workers
+//
+func propagationSummary(perfDataArray []WorkerPerfData, sampler *propagationSampler) *api.PropagationSummary {
+	summary := &api.PropagationSummary{Peers: len(sampler.peerURLs), ResolutionSeconds: roundSeconds(sampler.pollInterval)}
+	var spreads []time.Duration
+	for _, perfData := range perfDataArray {
+		spreads = append(spreads, perfData.propagationSpreads...)
+		summary.Incomplete += perfData.propagationIncomplete
+	}
+	summary.Samples = len(spreads) + summary.Incomplete
+	if len(spreads) == 0 {
+		return summary
+	}
+
+	latencies := summarizeLatencies(spreads)
+	summary.AverageSpreadSeconds = latencies.average
+	summary.P50SpreadSeconds = latencies.p50
+	summary.P90SpreadSeconds = latencies.p90
+	summary.P99SpreadSeconds = latencies.p99
+	summary.MaxSpreadSeconds = latencies.max
+	return summary
+}
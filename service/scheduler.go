@@ -0,0 +1,488 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/securekey/marbles-perf/api"
+	"github.com/securekey/marbles-perf/utils"
+)
+
+const (
+	overlapSkip  = "skip"
+	overlapAllow = "allow"
+	overlapQueue = "queue"
+
+	executionRunning = "running"
+	executionSkipped = "skipped"
+
+	configScheduleHistorySize  = "batch.schedule.history_size"
+	defaultScheduleHistorySize = 50
+
+	ledgerKeySchedules = "SCHEDULES"
+
+	// time between attempts at loading the schedules stored on the ledger
+	scheduleLoadRetryInterval = 10 * time.Second
+)
+
+var scheduler *batchScheduler
+
+// errSchedulesNotLoaded is returned by changes to the schedules until the stored schedules are loaded, which they would overwrite
+var errSchedulesNotLoaded = fmt.Errorf("schedules are not loaded from the ledger yet")
+
+// batchScheduler launches batch runs according to cron schedules.
+// Schedules are stored on the ledger and reloaded when the service starts, their execution history is kept in memory only.
+//
+type batchScheduler struct {
+	lock        sync.RWMutex
+	schedules   map[string]*scheduledRun
+	historySize int
+
+	// storeLock serializes the changes to the schedules stored on the ledger
+	storeLock sync.Mutex
+	loaded    bool // the stored schedules were loaded, guarded by storeLock
+}
+
+// scheduledRun is a schedule along with its runtime state
+//
+type scheduledRun struct {
+	lock        sync.Mutex
+	schedule    api.Schedule
+	cron        *utils.CronSchedule
+	history     []*api.ScheduleExecution
+	historySize int
+	stop        chan struct{}
+}
+
+func newBatchScheduler(historySize int) *batchScheduler {
+	if historySize <= 0 {
+		historySize = defaultScheduleHistorySize
+	}
+	return &batchScheduler{
+		schedules:   make(map[string]*scheduledRun),
+		historySize: historySize,
+	}
+}
+
+// newRun validates a schedule request and returns the schedule to add
+//
+func (s *batchScheduler) newRun(req api.ScheduleRequest) (*scheduledRun, error) {
+	cron, err := utils.ParseCron(req.Cron)
+	if err != nil {
+		return nil, err
+	}
+
+	policy := req.OverlapPolicy
+	switch policy {
+	case "":
+		policy = overlapSkip
+	case overlapSkip, overlapAllow, overlapQueue:
+	default:
+		return nil, fmt.Errorf("unknown overlap policy: %s, available policies are %s, %s and %s", policy, overlapSkip, overlapAllow, overlapQueue)
+	}
+	if err := validateAssignments(&req.Request); err != nil {
+		return nil, err
+	}
+
+	id, err := utils.GenerateRandomAlphaNumericString(16)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate random schedule id: %s", err)
+	}
+
+	return s.scheduledRun(api.Schedule{
+		ID:            "s" + id,
+		Cron:          req.Cron,
+		Request:       req.Request,
+		OverlapPolicy: policy,
+		CreatedAt:     time.Now(),
+	}, cron), nil
+}
+
+func (s *batchScheduler) scheduledRun(schedule api.Schedule, cron *utils.CronSchedule) *scheduledRun {
+	schedule.NextRun = cron.Next(time.Now())
+	return &scheduledRun{
+		schedule:    schedule,
+		cron:        cron,
+		historySize: s.historySize,
+		stop:        make(chan struct{}),
+	}
+}
+
+// add stores a new schedule on the ledger and starts it
+//
+func (s *batchScheduler) add(run *scheduledRun) error {
+	s.storeLock.Lock()
+	defer s.storeLock.Unlock()
+	if !s.loaded {
+		return errSchedulesNotLoaded
+	}
+
+	if err := storeSchedules(append(s.list(), run.status())); err != nil {
+		return err
+	}
+
+	s.lock.Lock()
+	s.schedules[run.schedule.ID] = run
+	s.lock.Unlock()
+
+	logger.Infof("added schedule %s: cron=%s, overlapPolicy=%s", run.schedule.ID, run.schedule.Cron, run.schedule.OverlapPolicy)
+	go run.loop()
+	return nil
+}
+
+// remove deletes a schedule from the ledger and stops it, runs in progress are not interrupted
+//
+func (s *batchScheduler) remove(id string) (bool, error) {
+	s.storeLock.Lock()
+	defer s.storeLock.Unlock()
+	if !s.loaded {
+		return false, errSchedulesNotLoaded
+	}
+
+	run, exists := s.get(id)
+	if !exists {
+		return false, nil
+	}
+
+	var remaining []api.Schedule
+	for _, schedule := range s.list() {
+		if schedule.ID != id {
+			remaining = append(remaining, schedule)
+		}
+	}
+	if err := storeSchedules(remaining); err != nil {
+		return true, err
+	}
+
+	s.lock.Lock()
+	delete(s.schedules, id)
+	s.lock.Unlock()
+
+	close(run.stop)
+	logger.Infof("removed schedule %s", id)
+	return true, nil
+}
+
+// loadInBackground loads the schedules stored on the ledger, retrying until the ledger can be read
+//
+func (s *batchScheduler) loadInBackground() {
+	go func() {
+		for {
+			err := s.load()
+			if err == nil {
+				return
+			}
+			logger.Warningf("failed to load schedules, retrying in %s: %s", scheduleLoadRetryInterval, err)
+			time.Sleep(scheduleLoadRetryInterval)
+		}
+	}()
+}
+
+// load starts the schedules stored on the ledger, skipping those that are not valid anymore
+//
+func (s *batchScheduler) load() error {
+	s.storeLock.Lock()
+	defer s.storeLock.Unlock()
+
+	schedules, err := readSchedules()
+	if err != nil {
+		return err
+	}
+
+	started := 0
+	for _, schedule := range schedules {
+		cron, err := utils.ParseCron(schedule.Cron)
+		if err != nil {
+			logger.Errorf("skipping stored schedule %s, invalid cron %s: %s", schedule.ID, schedule.Cron, err)
+			continue
+		}
+		run := s.scheduledRun(schedule, cron)
+
+		s.lock.Lock()
+		s.schedules[schedule.ID] = run
+		s.lock.Unlock()
+
+		go run.loop()
+		started++
+	}
+	s.loaded = true
+	logger.Infof("loaded %d of %d schedules stored on the ledger", started, len(schedules))
+	return nil
+}
+
+// isLoaded tells whether the schedules stored on the ledger were loaded
+//
+func (s *batchScheduler) isLoaded() bool {
+	s.storeLock.Lock()
+	defer s.storeLock.Unlock()
+	return s.loaded
+}
+
+// storeSchedules writes the definitions of the schedules to the ledger, without their runtime state
+func storeSchedules(schedules []api.Schedule) error {
+	definitions := make([]api.Schedule, 0, len(schedules))
+	for _, schedule := range schedules {
+		definitions = append(definitions, api.Schedule{
+			ID:            schedule.ID,
+			Cron:          schedule.Cron,
+			Request:       schedule.Request,
+			OverlapPolicy: schedule.OverlapPolicy,
+			CreatedAt:     schedule.CreatedAt,
+		})
+	}
+	schedulesJSON, err := json.Marshal(definitions)
+	if err != nil {
+		return fmt.Errorf("failed to JSON marshal schedules: %s", err)
+	}
+	return writeLedgerValue(ledgerKeySchedules, string(schedulesJSON))
+}
+
+// readSchedules reads the schedules stored on the ledger
+func readSchedules() ([]api.Schedule, error) {
+	data, err := readLedgerValue(ledgerKeySchedules)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, nil
+	}
+
+	var schedules []api.Schedule
+	if err := json.Unmarshal(data, &schedules); err != nil {
+		return nil, fmt.Errorf("failed to JSON unmarshal schedules: %s", err)
+	}
+	return schedules, nil
+}
+
+func (s *batchScheduler) get(id string) (*scheduledRun, bool) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	run, exists := s.schedules[id]
+	return run, exists
+}
+
+// list returns the status of all schedules ordered by creation time
+//
+func (s *batchScheduler) list() []api.Schedule {
+	s.lock.RLock()
+	schedules := make([]api.Schedule, 0, len(s.schedules))
+	for _, run := range s.schedules {
+		schedules = append(schedules, run.status())
+	}
+	s.lock.RUnlock()
+
+	sort.Slice(schedules, func(i, j int) bool { return schedules[i].CreatedAt.Before(schedules[j].CreatedAt) })
+	return schedules
+}
+
+// loop waits for each activation time of the schedule until it is stopped
+//
+func (r *scheduledRun) loop() {
+	for {
+		r.lock.Lock()
+		next := r.cron.Next(time.Now())
+		r.schedule.NextRun = next
+		r.lock.Unlock()
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-timer.C:
+			r.trigger(next)
+		case <-r.stop:
+			timer.Stop()
+			return
+		}
+	}
+}
+
+// trigger launches a run unless the overlap policy says otherwise
+//
+func (r *scheduledRun) trigger(scheduledAt time.Time) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.schedule.Running > 0 {
+		switch r.schedule.OverlapPolicy {
+		case overlapSkip:
+			logger.Infof("schedule %s: previous run still in progress, skipping run scheduled at %s", r.schedule.ID, scheduledAt)
+			r.record(&api.ScheduleExecution{ScheduledAt: scheduledAt, Status: executionSkipped})
+			return
+		case overlapQueue:
+			if r.schedule.Queued {
+				logger.Infof("schedule %s: a run is already queued, skipping run scheduled at %s", r.schedule.ID, scheduledAt)
+				r.record(&api.ScheduleExecution{ScheduledAt: scheduledAt, Status: executionSkipped})
+				return
+			}
+			logger.Infof("schedule %s: previous run still in progress, queuing run scheduled at %s", r.schedule.ID, scheduledAt)
+			r.schedule.Queued = true
+			return
+		}
+	}
+
+	r.start(scheduledAt)
+}
+
+// start launches a batch run, the caller must hold the lock
+//
+func (r *scheduledRun) start(scheduledAt time.Time) {
+	execution := &api.ScheduleExecution{ScheduledAt: scheduledAt, Status: executionRunning}
+	r.record(execution)
+
+	id, err := newBatchRunID()
+	if err != nil {
+		logger.Errorf("schedule %s: %s", r.schedule.ID, err)
+		execution.Status = err.Error()
+		return
+	}
+
+	startedAt := time.Now()
+	execution.BatchID = id
+	execution.StartedAt = &startedAt
+	r.schedule.Running++
+
+	go r.execute(execution)
+}
+
+// execute runs the batch of an execution to completion and starts the queued run if there is one
+//
+func (r *scheduledRun) execute(execution *api.ScheduleExecution) {
+	logger.Infof("schedule %s: starting batch run %s", r.schedule.ID, execution.BatchID)
+	result := doBatchTransfers(execution.BatchID, r.schedule.Request)
+	finishedAt := time.Now()
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	execution.FinishedAt = &finishedAt
+	execution.Status = result.Status
+	r.schedule.Running--
+	logger.Infof("schedule %s: batch run %s completed: %s", r.schedule.ID, execution.BatchID, result.Status)
+
+	if r.schedule.Queued && r.schedule.Running == 0 {
+		r.schedule.Queued = false
+		select {
+		case <-r.stop:
+			// schedule removed while the run was queued
+		default:
+			r.start(finishedAt)
+		}
+	}
+}
+
+// record adds an execution to the history, dropping the oldest ones beyond the history size.
+// The caller must hold the lock.
+//
+func (r *scheduledRun) record(execution *api.ScheduleExecution) {
+	r.history = append(r.history, execution)
+	if len(r.history) > r.historySize {
+		r.history = r.history[len(r.history)-r.historySize:]
+	}
+}
+
+func (r *scheduledRun) status() api.Schedule {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.schedule
+}
+
+// executions returns a copy of the execution history, most recent first
+//
+func (r *scheduledRun) executions() []api.ScheduleExecution {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	executions := make([]api.ScheduleExecution, len(r.history))
+	for i, execution := range r.history {
+		executions[len(r.history)-1-i] = *execution
+	}
+	return executions
+}
+
+// createSchedule creates a new recurring batch run
+//
+func createSchedule(w http.ResponseWriter, r *http.Request) {
+	payload, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, "failed to read request body: %s", err)
+		return
+	}
+
+	var req api.ScheduleRequest
+	if err := json.Unmarshal(payload, &req); err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, "failed to parse payload json: %s", err)
+		return
+	}
+
+	run, err := scheduler.newRun(req)
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := scheduler.add(run); err != nil {
+		writeErrorResponse(w, scheduleErrorStatus(err), err.Error())
+		return
+	}
+	writeJSONResponse(w, http.StatusOK, run.status())
+}
+
+// listSchedules lists all schedules
+//
+func listSchedules(w http.ResponseWriter, r *http.Request) {
+	writeJSONResponse(w, http.StatusOK, scheduler.list())
+}
+
+// getSchedule retrieves a schedule
+//
+func getSchedule(w http.ResponseWriter, r *http.Request) {
+	run, exists := scheduler.get(mux.Vars(r)["id"])
+	if !exists {
+		writeErrorResponse(w, http.StatusNotFound, "id not found")
+		return
+	}
+	writeJSONResponse(w, http.StatusOK, run.status())
+}
+
+// deleteSchedule stops and deletes a schedule
+//
+func deleteSchedule(w http.ResponseWriter, r *http.Request) {
+	exists, err := scheduler.remove(mux.Vars(r)["id"])
+	if err != nil {
+		writeErrorResponse(w, scheduleErrorStatus(err), err.Error())
+		return
+	}
+	if !exists {
+		writeErrorResponse(w, http.StatusNotFound, "id not found")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// getScheduleHistory retrieves the executions of a schedule
+//
+func getScheduleHistory(w http.ResponseWriter, r *http.Request) {
+	run, exists := scheduler.get(mux.Vars(r)["id"])
+	if !exists {
+		writeErrorResponse(w, http.StatusNotFound, "id not found")
+		return
+	}
+	writeJSONResponse(w, http.StatusOK, run.executions())
+}
+
+// scheduleErrorStatus returns the HTTP status of a failure to change the schedules
+func scheduleErrorStatus(err error) int {
+	if err == errSchedulesNotLoaded {
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,92 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/spf13/viper"
+)
+
+const (
+	configTLSEnabled      = "http.server.tls.enabled"
+	configTLSCertFile     = "http.server.tls.cert_file"
+	configTLSKeyFile      = "http.server.tls.key_file"
+	configTLSClientCAFile = "http.server.tls.client_ca_file"
+	configTLSClientAuth   = "http.server.tls.client_auth"
+
+	// clientAuthRequire rejects connections without a client certificate issued by the client CA
+	clientAuthRequire = "require"
+	// clientAuthRequest verifies the client certificate if one is presented, connections without one being accepted
+	clientAuthRequest = "request"
+)
+
+// listenAndServe serves the API over TLS if enabled in configuration, authenticating clients by certificate if a client CA is configured
+//
+func listenAndServe(srv *http.Server) error {
+	if !viper.GetBool(configTLSEnabled) {
+		return srv.ListenAndServe()
+	}
+
+	tlsConfig, err := serverTLSConfig()
+	if err != nil {
+		return err
+	}
+	srv.TLSConfig = tlsConfig
+	logger.Infof("Serving over TLS, client authentication: %s", clientAuthName(tlsConfig.ClientAuth))
+	return srv.ListenAndServeTLS(viper.GetString(configTLSCertFile), viper.GetString(configTLSKeyFile))
+}
+
+// serverTLSConfig returns the TLS configuration of the server, the certificate and key being loaded by ListenAndServeTLS
+func serverTLSConfig() (*tls.Config, error) {
+	if viper.GetString(configTLSCertFile) == "" || viper.GetString(configTLSKeyFile) == "" {
+		return nil, fmt.Errorf("configuration error, %s and %s are required when TLS is enabled", configTLSCertFile, configTLSKeyFile)
+	}
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	caFile := viper.GetString(configTLSClientCAFile)
+	if caFile == "" {
+		return tlsConfig, nil
+	}
+	caPEM, err := ioutil.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read client CA file %s: %s", caFile, err)
+	}
+	tlsConfig.ClientCAs = x509.NewCertPool()
+	if !tlsConfig.ClientCAs.AppendCertsFromPEM(caPEM) {
+		return nil, fmt.Errorf("no PEM encoded certificate in client CA file %s", caFile)
+	}
+
+	switch clientAuth := viper.GetString(configTLSClientAuth); clientAuth {
+	case "", clientAuthRequire:
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	case clientAuthRequest:
+		tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	default:
+		return nil, fmt.Errorf("configuration error, unknown %s: %s, available modes are %s and %s", configTLSClientAuth, clientAuth, clientAuthRequire, clientAuthRequest)
+	}
+	return tlsConfig, nil
+}
+
+// mutualTLSEnabled tells whether clients can be authenticated by certificate
+func mutualTLSEnabled() bool {
+	return viper.GetBool(configTLSEnabled) && viper.GetString(configTLSClientCAFile) != ""
+}
+
+func clientAuthName(clientAuth tls.ClientAuthType) string {
+	switch clientAuth {
+	case tls.RequireAndVerifyClientCert:
+		return clientAuthRequire
+	case tls.VerifyClientCertIfGiven:
+		return clientAuthRequest
+	default:
+		return "none"
+	}
+}
@@ -0,0 +1,64 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"github.com/securekey/marbles-perf/api"
+)
+
+const (
+	statusSLOViolated = "slo_violated"
+)
+
+// evaluateAssertions evaluates the SLO assertions that are set against the results of a batch run
+//
+func evaluateAssertions(assertions api.SLOAssertions, results api.BatchResult) []api.AssertionResult {
+	var outcomes []api.AssertionResult
+
+	if assertions.MaxP99TransferSeconds != nil {
+		outcomes = append(outcomes, assertAtMost("maxP99TransferSeconds", *assertions.MaxP99TransferSeconds, results.P99TransferSeconds))
+	}
+	if assertions.MaxAverageTransferSeconds != nil {
+		outcomes = append(outcomes, assertAtMost("maxAverageTransferSeconds", *assertions.MaxAverageTransferSeconds, results.AverageTransferSeconds))
+	}
+	if assertions.MinThroughput != nil {
+		outcomes = append(outcomes, assertAtLeast("minThroughput", *assertions.MinThroughput, results.Throughput))
+	}
+	if assertions.MaxErrorRate != nil {
+		outcomes = append(outcomes, assertAtMost("maxErrorRate", *assertions.MaxErrorRate, results.ErrorRate))
+	}
+
+	return outcomes
+}
+
+// assertionsPassed returns true if none of the assertion outcomes failed
+//
+func assertionsPassed(outcomes []api.AssertionResult) bool {
+	for _, outcome := range outcomes {
+		if !outcome.Passed {
+			return false
+		}
+	}
+	return true
+}
+
+func assertAtMost(assertion string, limit, actual float64) api.AssertionResult {
+	return api.AssertionResult{
+		Assertion: assertion,
+		Limit:     limit,
+		Actual:    actual,
+		Passed:    actual <= limit,
+	}
+}
+
+func assertAtLeast(assertion string, limit, actual float64) api.AssertionResult {
+	return api.AssertionResult{
+		Assertion: assertion,
+		Limit:     limit,
+		Actual:    actual,
+		Passed:    actual >= limit,
+	}
+}
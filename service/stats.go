@@ -0,0 +1,80 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// sortDurations sorts durations in ascending order
+//
+func sortDurations(durations []time.Duration) {
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+}
+
+// percentile returns the p-th percentile (0 < p <= 100) of the given ascending sorted durations
+// using the nearest-rank method
+//
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	if rank > len(sorted) {
+		rank = len(sorted)
+	}
+	return sorted[rank-1]
+}
+
+// roundSeconds returns the duration in seconds rounded to milliseconds
+//
+func roundSeconds(d time.Duration) float64 {
+	return roundMillis(d.Seconds())
+}
+
+// roundMillis rounds the value to 3 decimal places
+//
+func roundMillis(v float64) float64 {
+	return math.Round(v*1000) / 1000
+}
+
+// latencySummary summarizes durations in seconds rounded to milliseconds
+//
+type latencySummary struct {
+	count   int
+	total   time.Duration
+	average float64
+	min     float64
+	max     float64
+	p50     float64
+	p90     float64
+	p99     float64
+}
+
+// summarizeLatencies summarizes the given durations, sorting them in ascending order
+//
+func summarizeLatencies(durations []time.Duration) latencySummary {
+	summary := latencySummary{count: len(durations)}
+	if len(durations) == 0 {
+		return summary
+	}
+	sortDurations(durations)
+	for _, d := range durations {
+		summary.total += d
+	}
+	summary.average = roundMillis(summary.total.Seconds() / float64(len(durations)))
+	summary.min = roundSeconds(durations[0])
+	summary.max = roundSeconds(durations[len(durations)-1])
+	summary.p50 = roundSeconds(percentile(durations, 50))
+	summary.p90 = roundSeconds(percentile(durations, 90))
+	summary.p99 = roundSeconds(percentile(durations, 99))
+	return summary
+}
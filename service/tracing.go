@@ -0,0 +1,68 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"context"
+	"net/http"
+
+	fabricclient "github.com/securekey/marbles-perf/fabric-client"
+	"github.com/securekey/marbles-perf/utils"
+)
+
+// traceParentHeader is the W3C trace context header of callers
+const traceParentHeader = "traceparent"
+
+// traceHandler is a mux middleware starting a server span for each request,
+// continuing the trace of the caller if the request carries a trace context
+//
+func traceHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := routeTemplate(r)
+		ctx := r.Context()
+		if remote, ok := utils.ParseTraceParent(r.Header.Get(traceParentHeader)); ok {
+			ctx = utils.ContextWithRemoteSpanContext(ctx, remote)
+		}
+		ctx, span := utils.StartSpan(ctx, r.Method+" "+route,
+			utils.WithSpanKind(utils.SpanKindServer),
+			utils.WithAttributes("http.method", r.Method, "http.route", route))
+		defer span.End()
+
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, r.WithContext(ctx))
+
+		span.SetAttributes("http.status_code", recorder.status)
+	})
+}
+
+// invokeCC invokes marbles cc, the fabric client tracing the call within the span of ctx
+//
+func invokeCC(ctx context.Context, args []string, opts ...fabricclient.CallOption) (*fabricclient.CCResponse, error) {
+	return fc.InvokeCCContext(ctx, ConsortiumChannelID, MarblesCC, args, nil, opts...)
+}
+
+// queryCC queries marbles cc, the fabric client tracing the call within the span of ctx
+//
+func queryCC(ctx context.Context, args []string, opts ...fabricclient.CallOption) (*fabricclient.CCResponse, error) {
+	return fc.QueryCCContext(ctx, ConsortiumChannelID, MarblesCC, args, nil, opts...)
+}
+
+// endSpan ends a span, marking it failed if there is an error
+//
+func endSpan(span *utils.Span, err error) {
+	span.SetError(err)
+	span.End()
+}
+
+// startOperationSpan starts the root span of a batch worker operation within ctx, linked to the span of the batch run.
+// Operations get their own traces since a batch run can last for hours.
+//
+func (w *MarbleWorker) startOperationSpan(ctx context.Context, name string) (context.Context, *utils.Span) {
+	return utils.StartSpan(ctx, name,
+		utils.WithNewRoot(),
+		utils.WithLinks(w.tg.runSpanContext),
+		utils.WithAttributes("batch.id", w.tg.batchRunID, "batch.worker", w.id))
+}
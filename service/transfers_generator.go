@@ -6,7 +6,7 @@ SPDX-License-Identifier: Apache-2.0
 package main
 
 import (
-	"math"
+	"context"
 	"sync"
 	"time"
 
@@ -17,6 +17,8 @@ import (
 	"encoding/json"
 
 	"github.com/securekey/marbles-perf/api"
+	fabricclient "github.com/securekey/marbles-perf/fabric-client"
+	"github.com/securekey/marbles-perf/utils"
 )
 
 const (
@@ -27,22 +29,39 @@ const (
 	statusSuccess          = "success"
 	statusFailOwnerCreate  = "owner_create_failed"
 	statusFailMarbleCreate = "marble_create_failed"
+	statusCancelled        = "cancelled"
 )
 
 var colorArray = []string{"red", "orange", "yellow", "green", "blue", "indigo", "violet"}
 
 type WorkerPerfData struct {
-	transferTimes []time.Duration
-	successes     int
-	failures      int
-	status        string
+	transferTimes  []time.Duration
+	successes      int
+	failures       int
+	status         string
+	retryCounts    map[int]int    // number of transfers by number of retries
+	retriesByCause map[string]int // number of retries by status group/code
+	mismatches     int            // failed transfers whose endorsements differ
+	org            string         // organization of the worker, empty unless organizations are assigned
+	peers          map[string]*peerPerfData
+
+	propagationSpreads    []time.Duration // spreads of the sampled transfers visible at all peers
+	propagationIncomplete int             // sampled transfers not visible at all peers within the timeout
 }
 
 type MarbleWorker struct {
+	ctx      context.Context // done once the batch run is cancelled
 	id       int
-	tg       *TransfersGenerator
-	perfData *WorkerPerfData
-	wg       *sync.WaitGroup
+	identity string // user the worker transacts as, empty for the default user
+	org      string // organization the worker transacts as a member of, empty for the default organization
+	// peers endorsing the transfers of the worker, empty for the peers picked by selection
+	endorsers []string
+	orderer   string // orderer the transactions of the worker are sent to, empty for any orderer of the channel
+	// filter of fabric_sdk.peer_filters restricting the peers the worker's calls are sent to, empty for any peer
+	peerFilter string
+	tg         *TransfersGenerator
+	perfData   *WorkerPerfData
+	wg         *sync.WaitGroup
 }
 
 type TransfersGenerator struct {
@@ -50,49 +69,129 @@ type TransfersGenerator struct {
 	request    api.InitBatchRequest
 	owners     map[string]*api.Owner
 	ownerArray []string
+	runTime    time.Duration
+	log        *utils.FieldLogger
+
+	// blocks committed during the transfers, nil if blocks were not monitored
+	blockSummary *api.BlockSummary
+	// runtime statistics of the service during the transfers
+	runtimeStats *api.RuntimeStats
+	// whether the batch run was cancelled before workers completed their transfers
+	cancelled bool
+	// number of distinct identities assigned to workers, 0 if all workers use the default user
+	identityCount int
+	// measures the propagation of sampled transfers to all peers, nil unless requested
+	propagation     *propagationSampler
+	propagationWG   sync.WaitGroup // samples being measured
+	propagationLock sync.Mutex     // guards the propagation perf data of the workers
+	// comparison of the ledgers of all peers after the transfers, nil unless requested
+	consistency *api.ConsistencyReport
+
+	// span context of the batch run, linked from the spans of worker operations
+	runSpanContext utils.SpanContext
 }
 
 func NewTransfersGenerator(id string, req api.InitBatchRequest) *TransfersGenerator {
 	return &TransfersGenerator{
 		batchRunID: id,
 		request:    req,
+		log:        utils.NewFieldLogger(logger, utils.LogFieldBatchID, id),
 	}
 }
 
-func (tg *TransfersGenerator) run() {
+// run runs the batch of transfers within ctx, cancelling ctx stops the workers and abandons their fabric calls
+//
+func (tg *TransfersGenerator) run(ctx context.Context) api.BatchResult {
+	batchRunsInProgress.Inc()
+	defer batchRunsInProgress.Dec()
+
+	ctx, span := utils.StartSpan(ctx, "batch_run", utils.WithAttributes(
+		"batch.id", tg.batchRunID,
+		"batch.concurrency", tg.request.Concurrency,
+		"batch.iterations", tg.request.Iterations,
+	))
+	defer span.End()
+	tg.runSpanContext = span.SpanContext()
+
+	results := tg.execute(ctx)
+	span.SetAttributes("batch.status", results.Status)
+	return results
+}
+
+func (tg *TransfersGenerator) execute(ctx context.Context) api.BatchResult {
 	// Create array of perf data objects (one per worker)
 	perfData := make([]WorkerPerfData, tg.request.Concurrency)
 
-	logger.Infof("concurrency=%d, iterations=%d, extraDataLength=%d\n", tg.request.Concurrency, tg.request.Iterations, tg.request.ExtraDataLength)
-	if err := tg.initializeState(); err != nil {
-		logger.Errorf("failed to initialize state for batch run: %s", err)
-		tg.abortBatchRun(statusFailOwnerCreate)
-		return
+	tg.log.Infof("concurrency=%d, iterations=%d, extraDataLength=%d\n", tg.request.Concurrency, tg.request.Iterations, tg.request.ExtraDataLength)
+	if err := tg.initializeState(ctx); err != nil {
+		tg.log.Errorf("failed to initialize state for batch run: %s", err)
+		if ctx.Err() != nil {
+			return tg.abortBatchRun(statusCancelled)
+		}
+		return tg.abortBatchRun(statusFailOwnerCreate)
+	}
+
+	var monitor *blockMonitor
+	if blockMonitorEnabled() {
+		var err error
+		if monitor, err = startBlockMonitor(); err != nil {
+			tg.log.Warningf("failed to start block monitor, batch results will not include blocks: %s", err)
+		}
+	}
+
+	if tg.request.Propagation != nil {
+		if peerURLs, err := fc.PeerURLs(ConsortiumChannelID); err != nil {
+			tg.log.Warningf("failed to get the peers of the channel, propagation will not be measured: %s", err)
+		} else {
+			tg.propagation = newPropagationSampler(*tg.request.Propagation, peerURLs)
+		}
 	}
 
+	profiler := startRunProfiler(tg.batchRunID, tg.request.Profile, tg.log)
+
 	var wg sync.WaitGroup
 
+	identities := make(map[string]bool)
+	start := time.Now()
 	for workerId := 1; workerId <= tg.request.Concurrency; workerId++ {
 		perfData[workerId-1].transferTimes = make([]time.Duration, tg.request.Iterations)
 		worker := &MarbleWorker{
+			ctx:      ctx,
 			id:       workerId,
 			tg:       tg,
 			perfData: &perfData[workerId-1],
 			wg:       &wg,
 		}
+		tg.assignWorker(worker)
+		perfData[workerId-1].org = worker.org
+		if worker.identity != "" {
+			identities[worker.identity] = true
+		}
 
 		wg.Add(1)
 		go worker.startWorker()
 	}
 
+	tg.identityCount = len(identities)
 	wg.Wait()
+	tg.runTime = time.Since(start)
+	tg.cancelled = ctx.Err() != nil
+	tg.runtimeStats = profiler.stop()
+	if monitor != nil {
+		tg.blockSummary = monitor.summary()
+	}
+	// propagation samples still being measured are not part of the run time
+	tg.propagationWG.Wait()
+	if tg.request.ConsistencyCheck != nil {
+		tg.checkConsistency(ctx)
+	}
 
-	tg.processPerfData(perfData)
+	return tg.processPerfData(perfData)
 }
 
-func (tg *TransfersGenerator) initializeState() error {
+func (tg *TransfersGenerator) initializeState(ctx context.Context) error {
 	tg.populateUsers()
-	if err := tg.createOwners(); err != nil {
+	if err := tg.createOwners(ctx); err != nil {
 		return fmt.Errorf("failed to createOwners: %s", err)
 	}
 
@@ -116,11 +215,11 @@ func (tg *TransfersGenerator) populateUsers() {
 
 }
 
-func (tg *TransfersGenerator) createOwners() error {
+func (tg *TransfersGenerator) createOwners(ctx context.Context) error {
 
 	for _, o := range tg.owners {
 		// See if owner exists
-		owner, err := doGetOwner(o.Id)
+		owner, err := doGetOwner(ctx, o.Id)
 		if err == nil && owner != nil {
 			// User already exists
 			tg.owners[o.Id] = owner
@@ -128,32 +227,35 @@ func (tg *TransfersGenerator) createOwners() error {
 		}
 
 		// create new owner
-		if _, err := doCreateOwner(*o); err != nil {
+		if _, err := doCreateOwner(ctx, *o); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
-func (tg *TransfersGenerator) abortBatchRun(code string) {
-	logger.Errorf("aborting batch run %s: %s", tg.batchRunID, code)
-	tg.storeBatchRunResults(api.BatchResult{
+func (tg *TransfersGenerator) abortBatchRun(code string) api.BatchResult {
+	tg.log.Errorf("aborting batch run %s: %s", tg.batchRunID, code)
+	results := api.BatchResult{
+		BatchID: tg.batchRunID,
 		Status:  code,
 		Request: tg.request,
-	})
+	}
+	tg.storeBatchRunResults(results)
+	return results
 }
 
 func (tg *TransfersGenerator) writeLedger(key, value string) {
 	key = tg.batchRunID + key
-	if _, err := fc.InvokeCC(ConsortiumChannelID, MarblesCC, []string{"write", key, value}, nil); err != nil {
-		logger.Errorf("failed to write to ledger: %s: %s - %s", key, value, err)
+	if err := writeLedgerValue(key, value); err != nil {
+		tg.log.Errorf("%s - %s", err, value)
 	}
 }
 
 func (tg *TransfersGenerator) readLedger(key string) string {
 	key = tg.batchRunID + key
 	if resp, err := fc.InvokeCC(ConsortiumChannelID, MarblesCC, []string{"read", key}, nil); err != nil {
-		logger.Errorf("failed to read from ledger: %s: %s", key, err)
+		tg.log.Errorf("failed to read from ledger: %s: %s", key, err)
 	} else {
 		return string(resp.Payload)
 	}
@@ -161,6 +263,8 @@ func (tg *TransfersGenerator) readLedger(key string) string {
 }
 
 func (w *MarbleWorker) startWorker() {
+	batchWorkersActive.Inc()
+	defer batchWorkersActive.Dec()
 
 	// Create a marble
 	owner := w.tg.pickRandomOwner(nil)
@@ -174,29 +278,41 @@ func (w *MarbleWorker) startWorker() {
 
 	var marbleCreated bool
 	var err error
-	for i := 0; i < createMarbleMaxAttempts; i++ {
-		if resp, err := doCreateMarble(marble); err == nil {
+	for i := 0; i < createMarbleMaxAttempts && w.ctx.Err() == nil; i++ {
+		ctx, span := w.startOperationSpan(w.ctx, "batch.create_marble")
+		var resp api.Response
+		resp, err = doCreateMarble(ctx, marble, w.callOptions()...)
+		endSpan(span, err)
+		if err == nil {
 			marbleCreated = true
 			marble.Id = resp.Id
 			break
 		}
-		logger.Infof("Failed to create marble, attempt %d: %s", i, err)
+		w.tg.log.Infof("Failed to create marble, attempt %d: %s", i, err)
 	}
 	if !marbleCreated {
-		logger.Errorf("Error creating marble: Worker %d, Create marble %s for %s: %s", w.id, marble.Id, owner.Username, err)
+		w.tg.log.Errorf("Error creating marble: Worker %d, Create marble %s for %s: %s", w.id, marble.Id, owner.Username, err)
 		w.perfData.status = statusFailMarbleCreate
 		w.wg.Done()
 		return
 	}
 
-	logger.Infof("Worker %d, Marble %s created for %s", w.id, marble.Id, owner.Username)
+	w.tg.log.Infof("Worker %d, Marble %s created for %s", w.id, marble.Id, owner.Username)
 
 	prevOwner := owner
 
 	// Loop through each iteration of the test.
 	for t := 1; t <= w.tg.request.Iterations; t++ {
+		if w.ctx.Err() != nil {
+			w.tg.log.Infof("Worker %d, Marble %s: batch run cancelled after %d iterations", w.id, marble.Id, t-1)
+			break
+		}
 		if w.tg.request.DelaySeconds > 0 {
-			time.Sleep(time.Duration(w.tg.request.DelaySeconds) * time.Second)
+			select {
+			case <-time.After(time.Duration(w.tg.request.DelaySeconds) * time.Second):
+			case <-w.ctx.Done():
+				continue
+			}
 		}
 		newOwner := w.tg.pickRandomOwner(prevOwner)
 		transfer := api.Transfer{
@@ -204,41 +320,95 @@ func (w *MarbleWorker) startWorker() {
 			ToOwnerId:   newOwner.Id,
 			AuthCompany: prevOwner.Company,
 		}
+		ctx, span := w.startOperationSpan(w.ctx, "batch.transfer")
 		start := time.Now()
-		resp, err := doTransfer(transfer)
+		resp, err := doTransfer(ctx, transfer, w.callOptions()...)
+		endSpan(span, err)
+		duration := time.Since(start)
+		w.recordRetries(resp.Retries)
+		w.recordPeers(resp, duration, err)
 		if err == nil {
-			w.perfData.transferTimes[t-1] = time.Since(start)
+			w.perfData.transferTimes[t-1] = duration
 			w.perfData.successes++
-			logger.Debugf("Worker %d, Iteration %d: Marble %s transferred from %s to %s", w.id, t, marble.Id, prevOwner.Username, newOwner.Username)
+			batchTransfers.WithLabelValues(resultSuccess).Inc()
+			batchTransferDuration.Observe(w.perfData.transferTimes[t-1].Seconds())
+			w.tg.log.With(utils.LogFieldTxID, resp.TxId).Debugf("Worker %d, Iteration %d: Marble %s transferred from %s to %s", w.id, t, marble.Id, prevOwner.Username, newOwner.Username)
 			prevOwner = newOwner
+			if w.tg.propagation != nil && w.tg.propagation.sampled(t) {
+				w.recordPropagation(marble.Id, resp.TxId)
+			}
 		} else {
 			w.perfData.failures++
-			logger.Infof("Error transferring marble: Worker %d, Iteration %d: Transfer marble %s from %s to %s: %s", w.id, t, marble.Id, prevOwner.Username, newOwner.Username, resp.Error)
+			if fabricclient.IsEndorsementMismatch(err) {
+				w.perfData.mismatches++
+			}
+			batchTransfers.WithLabelValues(resultFailure).Inc()
+			w.tg.log.Infof("Error transferring marble: Worker %d, Iteration %d: Transfer marble %s from %s to %s: %s", w.id, t, marble.Id, prevOwner.Username, newOwner.Username, err)
 		}
 	}
 
-	logger.Infof("Worker %d, Marble %s finished for %s", w.id, marble.Id, owner.Username)
+	w.tg.log.Infof("Worker %d, Marble %s finished for %s", w.id, marble.Id, owner.Username)
 	if w.tg.request.ClearMarbles {
-		if _, err = doDeleteMarbleNoAuth(marble.Id); err != nil {
-			logger.Errorf("failed to delete marble after all work is done: %s", marble.Id)
+		// marbles are cleared even if the batch run was cancelled
+		ctx, span := w.startOperationSpan(context.Background(), "batch.delete_marble")
+		_, err = doDeleteMarbleNoAuth(ctx, marble.Id, w.callOptions()...)
+		endSpan(span, err)
+		if err != nil {
+			w.tg.log.Errorf("failed to delete marble after all work is done: %s", marble.Id)
 		}
 	}
 	w.wg.Done()
 }
 
+// recordRetries accounts for the retries of a transfer
+func (w *MarbleWorker) recordRetries(retries []api.Retry) {
+	if w.perfData.retryCounts == nil {
+		w.perfData.retryCounts = make(map[int]int)
+		w.perfData.retriesByCause = make(map[string]int)
+	}
+	w.perfData.retryCounts[len(retries)]++
+	for _, retry := range retries {
+		w.perfData.retriesByCause[fmt.Sprintf("%s/%d", retry.Group, retry.Code)]++
+	}
+}
+
+// recordPropagation measures the propagation of a transfer to all peers while the worker goes on with its next transfers
+func (w *MarbleWorker) recordPropagation(marbleID, txID string) {
+	w.tg.propagationWG.Add(1)
+	go func() {
+		defer w.tg.propagationWG.Done()
+		spread, complete := w.tg.propagation.measure(w.ctx, txID)
+		if w.ctx.Err() != nil {
+			// the batch run was cancelled while measuring
+			return
+		}
+
+		w.tg.propagationLock.Lock()
+		defer w.tg.propagationLock.Unlock()
+		if !complete {
+			w.perfData.propagationIncomplete++
+			w.tg.log.Warningf("Worker %d, Marble %s: transfer not committed at all peers within %s", w.id, marbleID, w.tg.propagation.timeout)
+			return
+		}
+		w.perfData.propagationSpreads = append(w.perfData.propagationSpreads, spread)
+	}()
+}
+
 // Process the collected data.
 // Note that durations are only captured for successes so we'll
 // ignore zero values as they are for errors.
-func (tg *TransfersGenerator) processPerfData(perfDataArray []WorkerPerfData) {
+func (tg *TransfersGenerator) processPerfData(perfDataArray []WorkerPerfData) api.BatchResult {
 
 	totalSuccesses := 0
 	totalFailures := 0
-	totalDuration := time.Duration(0)
-	maxDuration := time.Duration(0)
-	minDuration := time.Hour // Hopefully an hour is long enough to be a minimum
 
 	successWorkerCount := 0 // number of workers that have at least 1 successful transfer
 	var workerFailureStatus string
+	var transferTimes []time.Duration
+	totalRetries := 0
+	totalMismatches := 0
+	retryDistribution := make(map[int]int)
+	retriesByCause := make(map[string]int)
 
 	for _, perfData := range perfDataArray {
 		if perfData.successes > 0 {
@@ -248,58 +418,173 @@ func (tg *TransfersGenerator) processPerfData(perfDataArray []WorkerPerfData) {
 		}
 		totalSuccesses += perfData.successes
 		totalFailures += perfData.failures
+		for retries, transfers := range perfData.retryCounts {
+			retryDistribution[retries] += transfers
+			totalRetries += retries * transfers
+		}
+		for cause, retries := range perfData.retriesByCause {
+			retriesByCause[cause] += retries
+		}
+		totalMismatches += perfData.mismatches
 
 		for _, duration := range perfData.transferTimes {
 			if duration > 0 {
-				if duration > maxDuration {
-					maxDuration = duration
-				}
-				if duration < minDuration {
-					minDuration = duration
-				}
-				totalDuration += duration
+				transferTimes = append(transferTimes, duration)
 			}
 		}
 	}
+	latencies := summarizeLatencies(transferTimes)
 
-	avgTrfSecs := totalDuration.Seconds() / float64(totalSuccesses)
-	avgTrfSecs = math.Round(avgTrfSecs*1000) / 1000
-	minTrfSecs := math.Round(minDuration.Seconds()*1000) / 1000
-	maxTrfSecs := math.Round(maxDuration.Seconds()*1000) / 1000
+	var throughput, errorRate float64
+	if tg.runTime > 0 {
+		throughput = roundMillis(float64(totalSuccesses) / tg.runTime.Seconds())
+	}
+	if attempts := totalSuccesses + totalFailures; attempts > 0 {
+		errorRate = roundMillis(float64(totalFailures) / float64(attempts))
+	}
 
-	logger.Infof("batch run completed %s", tg.batchRunID)
-	logger.Infof("concurrency=%d, iterations=%d, extraDataLength=%d", tg.request.Concurrency, tg.request.Iterations, tg.request.ExtraDataLength)
-	logger.Infof("Total number of transfers:         %d", totalSuccesses)
-	logger.Infof("Total number of failures :         %d", totalFailures)
-	logger.Infof("Total seconds taken for successes: %d", int(totalDuration.Seconds()))
-	logger.Infof("Average seconds per transfer:      %3.3f", avgTrfSecs)
-	logger.Infof("Minimum seconds per transfer:      %3.3f", minTrfSecs)
-	logger.Infof("Maximum seconds per transfer:      %3.3f", maxTrfSecs)
+	tg.log.Infof("batch run completed %s", tg.batchRunID)
+	tg.log.Infof("concurrency=%d, iterations=%d, extraDataLength=%d", tg.request.Concurrency, tg.request.Iterations, tg.request.ExtraDataLength)
+	tg.log.Infof("Total number of transfers:         %d", totalSuccesses)
+	tg.log.Infof("Total number of failures :         %d", totalFailures)
+	tg.log.Infof("Total seconds taken for successes: %d", int(latencies.total.Seconds()))
+	tg.log.Infof("Average seconds per transfer:      %3.3f", latencies.average)
+	tg.log.Infof("Minimum seconds per transfer:      %3.3f", latencies.min)
+	tg.log.Infof("Maximum seconds per transfer:      %3.3f", latencies.max)
+	tg.log.Infof("p50/p90/p99 seconds per transfer:  %3.3f/%3.3f/%3.3f", latencies.p50, latencies.p90, latencies.p99)
+	tg.log.Infof("Transfers per second:              %3.3f", throughput)
+	tg.log.Infof("Total number of retries:           %d", totalRetries)
+	tg.log.Infof("Endorsement mismatches:            %d", totalMismatches)
 
 	runStatus := statusSuccess
 	if successWorkerCount < len(perfDataArray) {
 		// at least 1 worker didn't complete ANY transfers at all
 		runStatus = workerFailureStatus
 	}
+	if tg.cancelled {
+		runStatus = statusCancelled
+	}
 
 	results := api.BatchResult{
+		BatchID:                tg.batchRunID,
 		Request:                tg.request,
 		Status:                 runStatus,
 		TotalSuccesses:         totalSuccesses,
 		TotalFailures:          totalFailures,
-		TotalSuccessSeconds:    int(totalDuration.Seconds()),
-		AverageTransferSeconds: avgTrfSecs,
-		MinTransferSeconds:     minTrfSecs,
-		MaxTransferSeconds:     maxTrfSecs,
+		TotalSuccessSeconds:    int(latencies.total.Seconds()),
+		AverageTransferSeconds: latencies.average,
+		MinTransferSeconds:     latencies.min,
+		MaxTransferSeconds:     latencies.max,
+		P50TransferSeconds:     latencies.p50,
+		P90TransferSeconds:     latencies.p90,
+		P99TransferSeconds:     latencies.p99,
+		RunSeconds:             roundSeconds(tg.runTime),
+		Throughput:             throughput,
+		ErrorRate:              errorRate,
+		TotalRetries:           totalRetries,
+		EndorsementMismatches:  totalMismatches,
+		Identities:             tg.identityCount,
+		Selection:              batchSelection(),
+		SDKPoolSize:            batchPoolSize(),
+		RetryDistribution:      retryDistribution,
+		RetriesByCause:         retriesByCause,
+		Peers:                  peerStats(perfDataArray),
+		Organizations:          orgStats(perfDataArray, tg.runTime),
+		Blocks:                 tg.blockSummary,
+		Runtime:                tg.runtimeStats,
+	}
+	if tg.propagation != nil {
+		results.Propagation = propagationSummary(perfDataArray, tg.propagation)
+	}
+	if tg.consistency != nil {
+		results.Consistency = tg.consistency
+		if !tg.consistency.Consistent && results.Status == statusSuccess {
+			results.Status = statusLedgerDiverged
+		}
+	}
+
+	if tg.request.Assertions != nil {
+		results.Assertions = evaluateAssertions(*tg.request.Assertions, results)
+		if !assertionsPassed(results.Assertions) {
+			tg.log.Warningf("batch run %s violated its SLO assertions", tg.batchRunID)
+			if results.Status == statusSuccess {
+				results.Status = statusSLOViolated
+			}
+		}
+	}
+
+	if tg.request.Scenario != "" {
+		tg.compareWithBaseline(&results)
 	}
 
 	tg.storeBatchRunResults(results)
+
+	if tg.request.SetBaseline {
+		tg.setAsBaseline(results)
+	}
+
+	return results
+}
+
+// batchSelection returns the strategies the fabric client selects peers with
+//
+func batchSelection() *api.SelectionStrategies {
+	selection := fc.SelectionStrategies()
+	return &api.SelectionStrategies{Invoke: selection.Invoke, Query: selection.Query}
+}
+
+// batchPoolSize returns the number of SDK instances the fabric client spreads calls over
+//
+func batchPoolSize() int {
+	return fc.PoolSize()
+}
+
+// compareWithBaseline compares the results with the baseline of the run's scenario, if there is one
+//
+func (tg *TransfersGenerator) compareWithBaseline(results *api.BatchResult) {
+	baseline, err := readBaseline(tg.request.Scenario)
+	if err != nil {
+		tg.log.Errorf("failed to read baseline of scenario %s: %s", tg.request.Scenario, err)
+		return
+	}
+	if baseline == nil {
+		tg.log.Infof("no baseline for scenario %s, skipping comparison", tg.request.Scenario)
+		return
+	}
+
+	comparison := compareWithBaseline(*results, *baseline, effectiveTolerances(tg.request))
+	tg.log.Infof("batch run %s compared with baseline %s of scenario %s: %s", tg.batchRunID, baseline.BatchID, baseline.Scenario, comparison.Verdict)
+	results.Comparison = &comparison
+}
+
+// setAsBaseline makes a successful run the baseline of its scenario
+//
+func (tg *TransfersGenerator) setAsBaseline(results api.BatchResult) {
+	if tg.request.Scenario == "" {
+		tg.log.Errorf("batch run %s requested to be set as baseline but no scenario given", tg.batchRunID)
+		return
+	}
+	if results.Status != statusSuccess {
+		tg.log.Errorf("batch run %s not set as baseline of scenario %s: status %s", tg.batchRunID, tg.request.Scenario, results.Status)
+		return
+	}
+
+	// the baseline keeps its own results only, not how they compared to the previous baseline
+	results.Comparison = nil
+	baseline := api.Baseline{
+		Scenario: tg.request.Scenario,
+		BatchID:  tg.batchRunID,
+		Result:   results,
+	}
+	if err := storeBaseline(baseline); err != nil {
+		tg.log.Errorf("failed to set batch run %s as baseline of scenario %s: %s", tg.batchRunID, tg.request.Scenario, err)
+	}
 }
 
 func (tg *TransfersGenerator) storeBatchRunResults(results api.BatchResult) {
 	resultsJSON, err := json.MarshalIndent(results, "", "   ")
 	if err != nil {
-		logger.Errorf("failed to JSON marshal batch run results: %s", err)
+		tg.log.Errorf("failed to JSON marshal batch run results: %s", err)
 		return
 	}
 	tg.writeLedger(ledgerKeyBatchResults, string(resultsJSON))
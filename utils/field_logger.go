@@ -0,0 +1,98 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package utils
+
+import (
+	"strings"
+
+	logging "github.com/op/go-logging"
+)
+
+// Log field names
+const (
+	LogFieldBatchID = "batch_id"
+	LogFieldTxID    = "tx_id"
+	LogFieldWorker  = "worker"
+)
+
+// FieldLogger logs messages carrying key/value fields, eg. the batch id of a batch run.
+// Fields are written as a "[key=value ...]" message prefix, which the JSON log output turns into attributes.
+// Keys and values must not contain spaces.
+//
+type FieldLogger struct {
+	logger *logging.Logger
+	fields []string
+	prefix string
+}
+
+// NewFieldLogger returns a logger adding the given fields, as key, value pairs, to the messages logged with logger
+//
+func NewFieldLogger(logger *logging.Logger, keyvals ...string) *FieldLogger {
+	// report the caller of the FieldLogger methods rather than the methods themselves
+	wrapped := *logger
+	wrapped.ExtraCalldepth++
+	return newFieldLogger(&wrapped, keyvals)
+}
+
+func newFieldLogger(logger *logging.Logger, fields []string) *FieldLogger {
+	var pairs []string
+	for i := 0; i+1 < len(fields); i += 2 {
+		pairs = append(pairs, fields[i]+"="+fields[i+1])
+	}
+	var prefix string
+	if len(pairs) > 0 {
+		prefix = "[" + strings.Join(pairs, " ") + "] "
+	}
+	return &FieldLogger{logger: logger, fields: fields, prefix: prefix}
+}
+
+// With returns a logger adding the given fields, as key, value pairs, to the fields of l
+//
+func (l *FieldLogger) With(keyvals ...string) *FieldLogger {
+	fields := make([]string, 0, len(l.fields)+len(keyvals))
+	fields = append(append(fields, l.fields...), keyvals...)
+	return newFieldLogger(l.logger, fields)
+}
+
+// Debugf logs a message at debug level
+func (l *FieldLogger) Debugf(format string, args ...interface{}) {
+	l.logger.Debugf(l.prefix+format, args...)
+}
+
+// Infof logs a message at info level
+func (l *FieldLogger) Infof(format string, args ...interface{}) {
+	l.logger.Infof(l.prefix+format, args...)
+}
+
+// Warningf logs a message at warning level
+func (l *FieldLogger) Warningf(format string, args ...interface{}) {
+	l.logger.Warningf(l.prefix+format, args...)
+}
+
+// Errorf logs a message at error level
+func (l *FieldLogger) Errorf(format string, args ...interface{}) {
+	l.logger.Errorf(l.prefix+format, args...)
+}
+
+// splitLogFields splits a message logged by a FieldLogger into its fields and the message itself
+func splitLogFields(message string) (fields [][2]string, rest string) {
+	if !strings.HasPrefix(message, "[") {
+		return nil, message
+	}
+	end := strings.Index(message, "] ")
+	if end < 0 {
+		return nil, message
+	}
+	for _, pair := range strings.Fields(message[1:end]) {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			// not a field prefix
+			return nil, message
+		}
+		fields = append(fields, [2]string{kv[0], kv[1]})
+	}
+	return fields, message[end+2:]
+}
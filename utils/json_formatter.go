@@ -0,0 +1,58 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	logging "github.com/op/go-logging"
+)
+
+// jsonFormatter is a go-logging formatter writing records as JSON objects, eg. for log aggregators.
+// Fields logged with a FieldLogger become attributes of their own.
+//
+type jsonFormatter struct{}
+
+// Format implements logging.Formatter
+func (f *jsonFormatter) Format(calldepth int, r *logging.Record, w io.Writer) error {
+	fields, message := splitLogFields(strings.TrimRight(r.Message(), "\n"))
+
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	writeJSONField(&buf, "time", r.Time.Format(time.RFC3339Nano), true)
+	writeJSONField(&buf, "level", r.Level.String(), false)
+	writeJSONField(&buf, "module", r.Module, false)
+	if _, file, line, ok := runtime.Caller(calldepth + 1); ok {
+		writeJSONField(&buf, "caller", fmt.Sprintf("%s:%d", filepath.Base(file), line), false)
+	}
+	for _, field := range fields {
+		writeJSONField(&buf, field[0], field[1], false)
+	}
+	writeJSONField(&buf, "message", message, false)
+	buf.WriteByte('}')
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+func writeJSONField(buf *bytes.Buffer, key, value string, first bool) {
+	if !first {
+		buf.WriteByte(',')
+	}
+	// marshalling a string cannot fail
+	k, _ := json.Marshal(key)
+	v, _ := json.Marshal(value)
+	buf.Write(k)
+	buf.WriteByte(':')
+	buf.Write(v)
+}
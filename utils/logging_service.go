@@ -8,57 +8,257 @@ package utils
 import (
 	"fmt"
 	"os"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	logging "github.com/op/go-logging"
 	"github.com/spf13/viper"
 )
 
 const (
-	configLoggingFormat = "logging.format"
-	configLoggingLevel  = "logging.level"
-	defaultLogFormat    = "%{time:2006-01-02T15:04:05.999Z-05:00} %{shortfunc} ▶ %{level:.4s} %{id:03x} %{message}"
-	defaultLogLevel     = "info"
+	configLoggingFormat  = "logging.format"
+	configLoggingLevel   = "logging.level"
+	configLoggingOutput  = "logging.output"
+	configLoggingModules = "logging.modules"
+	defaultLogFormat     = "%{time:2006-01-02T15:04:05.999Z-05:00} %{shortfunc} ▶ %{level:.4s} %{id:03x} %{message}"
+	defaultLogLevel      = "info"
+
+	logOutputText = "text"
+	logOutputJSON = "json"
+)
+
+var (
+	levelsLock     sync.Mutex
+	leveledBackend logging.LeveledBackend
+	// levels set explicitly, by configuration or at runtime; the empty module holds the default level
+	moduleLevels = map[string]logging.Level{}
+	// modules whose level has ever been set, they must follow the default level once their own level is removed
+	knownModules = map[string]bool{}
+	levelReverts = map[string]*levelRevert{}
+	levelHooks   []func(module string, level logging.Level)
 )
 
-// InitLogger sets the logging format and level.
+// levelRevert restores the level a module had before a time-boxed level change
+type levelRevert struct {
+	timer    *time.Timer
+	at       time.Time
+	previous *logging.Level // nil if the module had no level of its own
+}
+
+// ModuleLevel is the log level of a module
+type ModuleLevel struct {
+	Module   string // empty for the default level
+	Level    logging.Level
+	RevertAt time.Time // zero unless the level is time-boxed
+}
+
+// InitLogger sets the logging output, format and levels.
+// The default level applies to all modules without a level of their own under logging.modules.
+//
 func InitLogger() error {
 	v := viper.GetViper()
 	logPattern := v.GetString(configLoggingFormat)
+	if len(logPattern) == 0 {
+		logPattern = defaultLogFormat
+	}
 	logLevel := v.GetString(configLoggingLevel)
-	if len(logPattern) > 0 && len(logLevel) > 0 {
-		return initLogging(logPattern, logLevel)
-	} else if len(logPattern) > 0 {
-		return initLogging(logPattern, defaultLogLevel)
-	} else if len(logLevel) > 0 {
-		return initLogging(defaultLogFormat, logLevel)
-	}
-	return initLogging(defaultLogFormat, defaultLogLevel)
+	if len(logLevel) == 0 {
+		logLevel = defaultLogLevel
+	}
+	return initLogging(v.GetString(configLoggingOutput), logPattern, logLevel, v.GetStringMapString(configLoggingModules))
 }
 
-func initLogging(pattern string, level string) error {
-	format := logging.MustStringFormatter(pattern)
+func initLogging(output string, pattern string, level string, modules map[string]string) error {
+	var format logging.Formatter
+	switch strings.ToLower(output) {
+	case "", logOutputText:
+		format = logging.MustStringFormatter(pattern)
+	case logOutputJSON:
+		format = &jsonFormatter{}
+	default:
+		return fmt.Errorf("unknown log output: %s, available log outputs are %s and %s", output, logOutputText, logOutputJSON)
+	}
+
+	defaultLevel, err := parseLogLevel(level)
+	if err != nil {
+		return err
+	}
+	levels := map[string]logging.Level{"": defaultLevel}
+	for module, moduleLevel := range modules {
+		if levels[module], err = parseLogLevel(moduleLevel); err != nil {
+			return fmt.Errorf("module %s: %s", module, err)
+		}
+	}
+
 	backend := logging.NewLogBackend(os.Stdout, "", 0)
 	formatter := logging.NewBackendFormatter(backend, format)
 	backendLeveled := logging.AddModuleLevel(formatter)
-	var logLevel logging.Level
+
+	levelsLock.Lock()
+	defer levelsLock.Unlock()
+	leveledBackend = backendLeveled
+	for module, moduleLevel := range levels {
+		moduleLevels[module] = moduleLevel
+		knownModules[module] = true
+	}
+	applyLevels()
+	logging.SetBackend(backendLeveled)
+	return nil
+}
+
+func parseLogLevel(level string) (logging.Level, error) {
 	switch strings.ToLower(level) {
 	case "critical":
-		logLevel = logging.CRITICAL
+		return logging.CRITICAL, nil
 	case "error":
-		logLevel = logging.ERROR
+		return logging.ERROR, nil
 	case "warning":
-		logLevel = logging.WARNING
+		return logging.WARNING, nil
 	case "notice":
-		logLevel = logging.NOTICE
+		return logging.NOTICE, nil
 	case "info":
-		logLevel = logging.INFO
+		return logging.INFO, nil
 	case "debug":
-		logLevel = logging.DEBUG
+		return logging.DEBUG, nil
 	default:
-		return fmt.Errorf("unknown log level: %s, available log levels are critical, error, warning, notice, info, and debug", level)
+		return logging.ERROR, fmt.Errorf("unknown log level: %s, available log levels are critical, error, warning, notice, info, and debug", level)
+	}
+}
+
+// setModuleLevel sets the level of a module, levelsLock must be held
+func setModuleLevel(module string, level logging.Level) {
+	moduleLevels[module] = level
+	knownModules[module] = true
+	applyLevels()
+}
+
+// removeModuleLevel makes a module follow the default level, levelsLock must be held
+func removeModuleLevel(module string) {
+	delete(moduleLevels, module)
+	applyLevels()
+}
+
+// applyLevels sets the effective level of all known modules in the backend and notifies the level hooks.
+// go-logging and fabric-sdk cannot unset the level of a module, so modules without a level of their own get the default level.
+func applyLevels() {
+	for module := range knownModules {
+		level, exists := moduleLevels[module]
+		if !exists {
+			level = moduleLevels[""]
+		}
+		leveledBackend.SetLevel(level, module)
+		for _, hook := range levelHooks {
+			hook(module, level)
+		}
+	}
+}
+
+// GetLogLevels returns the default log level, under the empty module, and the levels of modules having their own
+//
+func GetLogLevels() []ModuleLevel {
+	levelsLock.Lock()
+	defer levelsLock.Unlock()
+
+	var levels []ModuleLevel
+	for module, level := range moduleLevels {
+		moduleLevel := ModuleLevel{Module: module, Level: level}
+		if revert, exists := levelReverts[module]; exists {
+			moduleLevel.RevertAt = revert.at
+		}
+		levels = append(levels, moduleLevel)
+	}
+	sort.Slice(levels, func(i, j int) bool { return levels[i].Module < levels[j].Module })
+	return levels
+}
+
+// SetLogLevel sets the log level of a module, or the default level if module is empty.
+// If duration is positive, the module gets back the level it had before the change once duration elapses.
+// A later change of the module level cancels a pending revert, a time-boxed one reverting to the same level.
+//
+func SetLogLevel(module string, level string, duration time.Duration) error {
+	logLevel, err := parseLogLevel(level)
+	if err != nil {
+		return err
+	}
+
+	levelsLock.Lock()
+	defer levelsLock.Unlock()
+	if leveledBackend == nil {
+		return fmt.Errorf("logging not initialized")
+	}
+
+	var previous *logging.Level
+	if revert, exists := levelReverts[module]; exists {
+		revert.timer.Stop()
+		delete(levelReverts, module)
+		previous = revert.previous
+	} else if current, exists := moduleLevels[module]; exists {
+		previous = &current
+	}
+
+	if duration > 0 {
+		revert := &levelRevert{at: time.Now().Add(duration), previous: previous}
+		revert.timer = time.AfterFunc(duration, func() { revertLogLevel(module, revert) })
+		levelReverts[module] = revert
+	}
+
+	setModuleLevel(module, logLevel)
+	return nil
+}
+
+// ResetLogLevel removes the level of a module, which then follows the default level
+//
+func ResetLogLevel(module string) error {
+	if module == "" {
+		return fmt.Errorf("the default log level cannot be removed")
+	}
+
+	levelsLock.Lock()
+	defer levelsLock.Unlock()
+	if revert, exists := levelReverts[module]; exists {
+		revert.timer.Stop()
+		delete(levelReverts, module)
+	}
+	if _, exists := moduleLevels[module]; exists {
+		removeModuleLevel(module)
 	}
-	backendLeveled.SetLevel(logLevel, "")
-	logging.SetBackend(backendLeveled)
 	return nil
 }
+
+func revertLogLevel(module string, revert *levelRevert) {
+	levelsLock.Lock()
+	defer levelsLock.Unlock()
+	if levelReverts[module] != revert {
+		// superseded by a later change
+		return
+	}
+	delete(levelReverts, module)
+
+	if revert.previous != nil {
+		setModuleLevel(module, *revert.previous)
+	} else {
+		removeModuleLevel(module)
+	}
+	logging.MustGetLogger("utils").Infof("log level of module '%s' reverted to %s", module, LogLevelName(leveledBackend.GetLevel(module)))
+}
+
+// LogLevelName returns the name of a log level as used in configuration
+//
+func LogLevelName(level logging.Level) string {
+	return strings.ToLower(level.String())
+}
+
+// OnLevelChange registers a function called with the level of every module set so far, and then whenever
+// the level of a module changes. It allows loggers not backed by go-logging, eg. fabric-sdk's, to follow the configured levels.
+// The empty module stands for the default level.
+//
+func OnLevelChange(hook func(module string, level logging.Level)) {
+	levelsLock.Lock()
+	defer levelsLock.Unlock()
+	levelHooks = append(levelHooks, hook)
+	for module := range knownModules {
+		hook(module, leveledBackend.GetLevel(module))
+	}
+}
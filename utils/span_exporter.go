@@ -0,0 +1,221 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// spanExporter records spans when they end, the span lock being held
+type spanExporter interface {
+	export(s *Span) error
+}
+
+// logExporter logs spans through the tracing module
+type logExporter struct{}
+
+func (logExporter) export(s *Span) error {
+	fields := []string{LogFieldTraceID, s.context.TraceID, LogFieldSpanID, s.context.SpanID}
+	if s.parentID != "" {
+		fields = append(fields, LogFieldParentID, s.parentID)
+	}
+	msg := fmt.Sprintf("span %q service=%s kind=%s duration=%s", s.name, tracingServiceName, s.kind, s.end.Sub(s.start))
+	for _, link := range s.links {
+		if link.IsValid() {
+			msg += fmt.Sprintf(" link=%s/%s", link.TraceID, link.SpanID)
+		}
+	}
+	for _, attribute := range s.attributes {
+		msg += fmt.Sprintf(" %s=%s", attribute.key, formatAttributeValue(attribute.value))
+	}
+	if s.err != nil {
+		msg += fmt.Sprintf(" error=%q", s.err.Error())
+	}
+	newFieldLogger(spanLogger, fields).Infof("%s", msg)
+	return nil
+}
+
+// fileExporter appends spans to a file, one OTLP JSON ExportTraceServiceRequest per line,
+// as read by the file receiver of the OpenTelemetry collector
+type fileExporter struct {
+	lock sync.Mutex
+	file *os.File
+}
+
+func newFileExporter(path string) (*fileExporter, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open span file %s: %s", path, err)
+	}
+	return &fileExporter{file: file}, nil
+}
+
+func (e *fileExporter) export(s *Span) error {
+	record, err := json.Marshal(otlpRecord(s))
+	if err != nil {
+		return fmt.Errorf("failed to JSON marshal span: %s", err)
+	}
+
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	_, err = e.file.Write(append(record, '\n'))
+	return err
+}
+
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              int            `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Links             []otlpLink     `json:"links,omitempty"`
+	Status            otlpStatus     `json:"status"`
+}
+
+type otlpLink struct {
+	TraceID string `json:"traceId"`
+	SpanID  string `json:"spanId"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+type otlpAnyValue struct {
+	StringValue *string         `json:"stringValue,omitempty"`
+	BoolValue   *bool           `json:"boolValue,omitempty"`
+	IntValue    *string         `json:"intValue,omitempty"` // 64 bit integers are strings in protobuf JSON
+	DoubleValue *float64        `json:"doubleValue,omitempty"`
+	ArrayValue  *otlpArrayValue `json:"arrayValue,omitempty"`
+}
+
+type otlpArrayValue struct {
+	Values []otlpAnyValue `json:"values"`
+}
+
+// OTLP span kinds and status codes
+const (
+	otlpKindInternal = 1
+	otlpKindServer   = 2
+	otlpKindClient   = 3
+
+	otlpStatusError = 2
+)
+
+// otlpRecord encodes a span as an OTLP ExportTraceServiceRequest of its own
+func otlpRecord(s *Span) otlpRequest {
+	span := otlpSpan{
+		TraceID:           s.context.TraceID,
+		SpanID:            s.context.SpanID,
+		ParentSpanID:      s.parentID,
+		Name:              s.name,
+		Kind:              otlpKind(s.kind),
+		StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(s.end.UnixNano(), 10),
+	}
+	for _, attribute := range s.attributes {
+		span.Attributes = append(span.Attributes, otlpKeyValue{Key: attribute.key, Value: otlpValue(attribute.value)})
+	}
+	for _, link := range s.links {
+		if link.IsValid() {
+			span.Links = append(span.Links, otlpLink{TraceID: link.TraceID, SpanID: link.SpanID})
+		}
+	}
+	if s.err != nil {
+		span.Status = otlpStatus{Code: otlpStatusError, Message: s.err.Error()}
+	}
+
+	return otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource: otlpResource{Attributes: []otlpKeyValue{{Key: "service.name", Value: otlpValue(tracingServiceName)}}},
+		ScopeSpans: []otlpScopeSpans{{
+			Scope: otlpScope{Name: "marbles-perf"},
+			Spans: []otlpSpan{span},
+		}},
+	}}}
+}
+
+func otlpKind(kind string) int {
+	switch kind {
+	case SpanKindServer:
+		return otlpKindServer
+	case SpanKindClient:
+		return otlpKindClient
+	default:
+		return otlpKindInternal
+	}
+}
+
+func otlpValue(value interface{}) otlpAnyValue {
+	switch v := value.(type) {
+	case bool:
+		return otlpAnyValue{BoolValue: &v}
+	case int:
+		return otlpIntValue(int64(v))
+	case int32:
+		return otlpIntValue(int64(v))
+	case int64:
+		return otlpIntValue(v)
+	case uint64:
+		return otlpIntValue(int64(v))
+	case float64:
+		return otlpAnyValue{DoubleValue: &v}
+	case time.Duration:
+		s := v.String()
+		return otlpAnyValue{StringValue: &s}
+	case []string:
+		values := make([]otlpAnyValue, 0, len(v))
+		for _, s := range v {
+			values = append(values, otlpValue(s))
+		}
+		return otlpAnyValue{ArrayValue: &otlpArrayValue{Values: values}}
+	case string:
+		return otlpAnyValue{StringValue: &v}
+	default:
+		s := fmt.Sprint(v)
+		return otlpAnyValue{StringValue: &s}
+	}
+}
+
+func otlpIntValue(i int64) otlpAnyValue {
+	s := strconv.FormatInt(i, 10)
+	return otlpAnyValue{IntValue: &s}
+}
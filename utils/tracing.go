@@ -0,0 +1,311 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package utils
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	mathrand "math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	logging "github.com/op/go-logging"
+	"github.com/spf13/viper"
+)
+
+const (
+	configTracingEnabled     = "tracing.enabled"
+	configTracingServiceName = "tracing.service_name"
+	configTracingSampleRatio = "tracing.sample_ratio"
+	configTracingExporter    = "tracing.exporter"
+	configTracingFilePath    = "tracing.file.path"
+
+	defaultTracingServiceName = "marbles-perf"
+
+	// TracingModule is the logging module spans are logged by when they end
+	TracingModule = "tracing"
+
+	// Span exporters
+	ExporterLog  = "log"
+	ExporterFile = "file"
+
+	// Span kinds
+	SpanKindInternal = "internal"
+	SpanKindServer   = "server"
+	SpanKindClient   = "client"
+
+	// Log field names of spans
+	LogFieldTraceID  = "trace_id"
+	LogFieldSpanID   = "span_id"
+	LogFieldParentID = "parent_id"
+)
+
+var (
+	tracingEnabled     bool
+	tracingServiceName = defaultTracingServiceName
+	tracingSampleRatio = 1.0
+	spanLogger         = logging.MustGetLogger(TracingModule)
+	exporter           spanExporter
+)
+
+// InitTracing enables tracing as configured. Spans are exported when they end, either logged by the tracing module
+// with their trace, span and parent ids as log fields, or written to a file as OTLP JSON records for offline analysis.
+// Tracing is disabled unless tracing.enabled is set, in which case spans are not recorded.
+//
+func InitTracing() error {
+	v := viper.GetViper()
+	tracingEnabled = v.GetBool(configTracingEnabled)
+	if !tracingEnabled {
+		return nil
+	}
+
+	if serviceName := v.GetString(configTracingServiceName); serviceName != "" {
+		tracingServiceName = serviceName
+	}
+	if v.IsSet(configTracingSampleRatio) {
+		tracingSampleRatio = v.GetFloat64(configTracingSampleRatio)
+		if tracingSampleRatio < 0 || tracingSampleRatio > 1 {
+			return fmt.Errorf("configuration error, %s must be between 0 and 1: %v", configTracingSampleRatio, tracingSampleRatio)
+		}
+	}
+
+	switch exporterName := v.GetString(configTracingExporter); exporterName {
+	case "", ExporterLog:
+		exporter = logExporter{}
+	case ExporterFile:
+		path := v.GetString(configTracingFilePath)
+		if path == "" {
+			return fmt.Errorf("configuration error, %s is required by the %s exporter", configTracingFilePath, ExporterFile)
+		}
+		fileExporter, err := newFileExporter(path)
+		if err != nil {
+			return err
+		}
+		exporter = fileExporter
+	default:
+		return fmt.Errorf("configuration error, unknown %s: %s, available exporters are %s and %s", configTracingExporter, exporterName, ExporterLog, ExporterFile)
+	}
+	return nil
+}
+
+// SpanContext identifies a span and its trace
+type SpanContext struct {
+	TraceID string // 32 hex digits
+	SpanID  string // 16 hex digits
+	Sampled bool
+}
+
+// IsValid tells whether the span context identifies a span
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID != "" && sc.SpanID != ""
+}
+
+// ParseTraceParent parses a W3C traceparent header, eg. 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01
+//
+func ParseTraceParent(traceParent string) (SpanContext, bool) {
+	parts := strings.Split(strings.TrimSpace(traceParent), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return SpanContext{}, false
+	}
+	flags, err := hex.DecodeString(parts[3])
+	if err != nil || !isHex(parts[1]) || !isHex(parts[2]) || strings.Trim(parts[1], "0") == "" || strings.Trim(parts[2], "0") == "" {
+		return SpanContext{}, false
+	}
+	return SpanContext{TraceID: parts[1], SpanID: parts[2], Sampled: flags[0]&1 == 1}, true
+}
+
+func isHex(s string) bool {
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
+
+// Span is an operation of a trace. Methods of a nil span, returned when tracing is disabled, do nothing.
+type Span struct {
+	context  SpanContext
+	parentID string
+	name     string
+	kind     string
+	start    time.Time
+	links    []SpanContext
+
+	lock       sync.Mutex
+	attributes []spanAttribute
+	err        error
+	end        time.Time // zero until the span ended
+}
+
+type spanAttribute struct {
+	key   string
+	value interface{}
+}
+
+type spanKey struct{}
+
+type remoteSpanKey struct{}
+
+// ContextWithRemoteSpanContext returns a context in which spans continue the trace of a caller
+//
+func ContextWithRemoteSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, remoteSpanKey{}, sc)
+}
+
+// SpanFromContext returns the current span of ctx, nil if none
+//
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
+
+// spanOptions are the options of a span being started
+type spanOptions struct {
+	kind       string
+	newRoot    bool
+	links      []SpanContext
+	attributes []interface{}
+}
+
+// SpanOption sets an option of a span being started
+type SpanOption func(*spanOptions)
+
+// WithSpanKind sets the kind of the span, SpanKindInternal by default
+//
+func WithSpanKind(kind string) SpanOption {
+	return func(o *spanOptions) {
+		o.kind = kind
+	}
+}
+
+// WithNewRoot starts the span in a new trace, ignoring the current span of the context
+//
+func WithNewRoot() SpanOption {
+	return func(o *spanOptions) {
+		o.newRoot = true
+	}
+}
+
+// WithLinks links the span to spans of other traces
+//
+func WithLinks(links ...SpanContext) SpanOption {
+	return func(o *spanOptions) {
+		o.links = append(o.links, links...)
+	}
+}
+
+// WithAttributes sets attributes of the span, as key, value pairs
+//
+func WithAttributes(keyvals ...interface{}) SpanOption {
+	return func(o *spanOptions) {
+		o.attributes = append(o.attributes, keyvals...)
+	}
+}
+
+// StartSpan starts a span, child of the current span of ctx if any, and returns a context in which it is the current span.
+// It returns a nil span if tracing is disabled.
+//
+func StartSpan(ctx context.Context, name string, opts ...SpanOption) (context.Context, *Span) {
+	if !tracingEnabled {
+		return ctx, nil
+	}
+	options := spanOptions{kind: SpanKindInternal}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	span := &Span{name: name, kind: options.kind, start: time.Now(), links: options.links}
+	var parent SpanContext
+	if !options.newRoot {
+		if current := SpanFromContext(ctx); current != nil {
+			parent = current.context
+		} else if remote, ok := ctx.Value(remoteSpanKey{}).(SpanContext); ok {
+			parent = remote
+		}
+	}
+	if parent.IsValid() {
+		span.context = SpanContext{TraceID: parent.TraceID, SpanID: newID(8), Sampled: parent.Sampled}
+		span.parentID = parent.SpanID
+	} else {
+		span.context = SpanContext{TraceID: newID(16), SpanID: newID(8), Sampled: mathrand.Float64() < tracingSampleRatio}
+	}
+	span.SetAttributes(options.attributes...)
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+func newID(bytes int) string {
+	id := make([]byte, bytes)
+	if _, err := rand.Read(id); err != nil {
+		mathrand.Read(id)
+	}
+	return hex.EncodeToString(id)
+}
+
+// SpanContext returns the identity of the span, invalid for a nil span
+//
+func (s *Span) SpanContext() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.context
+}
+
+// SetAttributes sets attributes of the span, as key, value pairs
+//
+func (s *Span) SetAttributes(keyvals ...interface{}) {
+	if s == nil || !s.context.Sampled {
+		return
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for i := 0; i+1 < len(keyvals); i += 2 {
+		s.attributes = append(s.attributes, spanAttribute{key: fmt.Sprint(keyvals[i]), value: keyvals[i+1]})
+	}
+}
+
+// formatAttributeValue formats an attribute value, quoted if it has spaces
+func formatAttributeValue(value interface{}) string {
+	var s string
+	switch v := value.(type) {
+	case []string:
+		s = strings.Join(v, ",")
+	default:
+		s = fmt.Sprintf("%v", v)
+	}
+	if s == "" || strings.ContainsAny(s, " \t\n\"") {
+		return fmt.Sprintf("%q", s)
+	}
+	return s
+}
+
+// SetError marks the span failed
+//
+func (s *Span) SetError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.err = err
+}
+
+// End ends the span, exporting it if its trace is sampled
+//
+func (s *Span) End() {
+	if s == nil || !s.context.Sampled {
+		return
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if !s.end.IsZero() {
+		return
+	}
+	s.end = time.Now()
+
+	if err := exporter.export(s); err != nil {
+		spanLogger.Warningf("failed to export span %q: %s", s.name, err)
+	}
+}